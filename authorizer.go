@@ -0,0 +1,50 @@
+package s3v2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Authorizer decides whether the identity that signed a verified request
+// may make it, such as from bucket and key ACLs or IAM like policies. Allow
+// returns nil to let the request through. An error that is a *VerifyError
+// is responded with as it is, and any other error is responded with as an
+// S3 AccessDenied error wrapping ErrNotAuthorized.
+type Authorizer interface {
+	Allow(VerifiedRequest) error
+}
+
+// AuthorizerFunc is an Authorizer function
+type AuthorizerFunc func(VerifiedRequest) error
+
+// Allow calls f(vr)
+func (f AuthorizerFunc) Allow(vr VerifiedRequest) error {
+	return f(vr)
+}
+
+// WithAuthorizer authorizes the requests the Verifier middleware verifies
+// with authorizer, after their signature is checked
+func WithAuthorizer(authorizer Authorizer) VerifierOption {
+	return func(v *Verifier) {
+		v.authorizer = authorizer
+	}
+}
+
+// authorize returns the error a request the authorizer denies is rejected
+// with, or nil if it is allowed or there is no authorizer
+func (v *Verifier) authorize(vr VerifiedRequest) error {
+	if v.authorizer == nil {
+		return nil
+	}
+	err := v.authorizer.Allow(vr)
+	if err == nil {
+		return nil
+	}
+	var verr *VerifyError
+	if errors.As(err, &verr) {
+		return err
+	}
+	verr = errAccessDenied("Access Denied")
+	verr.Err = fmt.Errorf("%w: %w", ErrNotAuthorized, err)
+	return verr
+}
@@ -0,0 +1,73 @@
+package s3v2
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareAuthorizer(t *testing.T) {
+	assert := assert.New(t)
+
+	var denied error
+	calls := 0
+	authorizer := AuthorizerFunc(func(vr VerifiedRequest) error {
+		calls++
+		if vr.Bucket == "private" && vr.Method != http.MethodGet {
+			return denied
+		}
+		return nil
+	})
+	handler := NewVerifier(testSecretLookup, WithAuthorizer(authorizer)).
+		Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, nil)
+		signTestRequest(r)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	denied = errors.New("read only bucket")
+	assert.Equal(http.StatusOK, serve("GET", "https://private.s3.amazonaws.com/photos/puppy.jpg").Code)
+	assert.Equal(http.StatusOK, serve("PUT", "https://johnsmith.s3.amazonaws.com/photos/puppy.jpg").Code)
+	w := serve("PUT", "https://private.s3.amazonaws.com/photos/puppy.jpg")
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.Equal("application/xml", w.Header().Get("Content-Type"))
+	assert.Contains(w.Body.String(), "<Code>AccessDenied</Code>")
+	assert.Contains(w.Body.String(), "<Message>Access Denied</Message>")
+
+	// a VerifyError is responded with as it is
+	denied = &VerifyError{Code: "AllAccessDisabled", Message: "All access to this object has been disabled",
+		StatusCode: http.StatusForbidden}
+	w = serve("PUT", "https://private.s3.amazonaws.com/photos/puppy.jpg")
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.Contains(w.Body.String(), "<Code>AllAccessDisabled</Code>")
+
+	// requests failing verification never reach the authorizer
+	calls = 0
+	r := httptest.NewRequest("PUT", "https://private.s3.amazonaws.com/photos/puppy.jpg", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(http.StatusForbidden, w.Code)
+	assert.Zero(calls)
+}
+
+func TestAuthorize(t *testing.T) {
+	assert := assert.New(t)
+
+	cause := errors.New("no policy allows s3:PutObject")
+	v := NewVerifier(testSecretLookup, WithAuthorizer(AuthorizerFunc(func(VerifiedRequest) error { return cause })))
+	err := v.authorize(VerifiedRequest{})
+	assert.ErrorIs(err, ErrNotAuthorized)
+	assert.ErrorIs(err, cause)
+	var verr *VerifyError
+	assert.ErrorAs(err, &verr)
+	assert.Equal("AccessDenied", verr.Code)
+
+	assert.NoError(NewVerifier(testSecretLookup).authorize(VerifiedRequest{}))
+}
@@ -0,0 +1,298 @@
+// Package awsv1 signs the requests of aws-sdk-go service clients with the
+// V2 signature of package s3v2, by swapping it in for the V4 signer:
+//
+//	svc := s3.New(sess)
+//	awsv1.SwapSigner(svc.Client)
+package awsv1
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/benmcclelland/s3v2"
+)
+
+// RetryClockSkewErrors is a request.Option that makes RequestTimeTooSkewed
+// errors retryable. The retry is signed with the clock of the server, taken
+// from the Date of the rejected response.
+func RetryClockSkewErrors(r *request.Request) {
+	r.RetryErrorCodes = append(r.RetryErrorCodes, "RequestTimeTooSkewed")
+}
+
+// SignRequestHandler is a named request handler the SDK will use to sign
+// service client request with using the V2 signature.
+var SignRequestHandler = BuildNamedHandler("v2.SignRequestHandler")
+
+// BuildNamedHandler will build a generic handler for signing.
+func BuildNamedHandler(name string, opts ...s3v2.Option) request.NamedHandler {
+	return request.NamedHandler{
+		Name: name,
+		Fn: func(req *request.Request) {
+			signSDKRequest(req, nil, opts...)
+		},
+	}
+}
+
+// SwapSigner replaces the V4 signer of the service client with the V2
+// signer. The V2 signer takes the place of the V4 signer so it still runs
+// after the handlers the SDK puts ahead of it, such as the one setting the
+// Content-Length. Swapping a client that already has the V2 signer replaces
+// it in place, and a client with neither has the V2 signer added to the end
+// of the sign handlers.
+func SwapSigner(c *client.Client, opts ...s3v2.Option) {
+	handler := SignRequestHandler
+	if len(opts) > 0 {
+		handler = BuildNamedHandler(SignRequestHandler.Name, opts...)
+	}
+	swapSignHandler(c, handler)
+}
+
+// swapSignHandler replaces the V4 signer, or the V2 signer, of the service
+// client with handler, or adds it to the end of the sign handlers
+func swapSignHandler(c *client.Client, handler request.NamedHandler) {
+	if c.Handlers.Sign.Swap(v4.SignRequestHandler.Name, handler) ||
+		c.Handlers.Sign.SwapNamed(handler) {
+		return
+	}
+	c.Handlers.Sign.PushBackNamed(handler)
+}
+
+// SignSDKRequest requests with signature version 2.
+//
+// Will sign the requests with the service config's Credentials object, or
+// the Credentials of the RequestOptions of the request context.
+// Signing is skipped if the credentials is the credentials.AnonymousCredentials
+// object, or any credentials without an access key id.
+//
+// This is intended to be specific to S3, for others use v2 or v4
+func SignSDKRequest(req *request.Request) {
+	SignSDKRequestWithCurrentTime(req, time.Now)
+}
+
+// SignSDKRequestWithCurrentTime will sign the SDK's request using the time
+// function passed in. Behaves the same as SignSDKRequest with the exception
+// the request is signed with the value returned by the current time function.
+//
+// The Date header is set to the signing time on every attempt, so retries
+// of the same request are signed with a fresh Date. When the previous
+// attempt was rejected by a server whose clock is skewed from the local
+// clock, the retry is signed with the server's time.
+func SignSDKRequestWithCurrentTime(req *request.Request, curTimeFn func() time.Time, opts ...s3v2.Option) {
+	signSDKRequest(req, curTimeFn, opts...)
+}
+
+// signSDKRequest signs the SDK request with the time of curTimeFn, or of
+// the clock of the options if it is nil
+func signSDKRequest(req *request.Request, curTimeFn func() time.Time, opts ...s3v2.Option) {
+	markSignHandlerRan(req)
+
+	// If the request does not need to be signed ignore the signing of the
+	// request if the AnonymousCredentials object is used.
+	creds := requestCredentials(req)
+	reqOpts, _ := RequestOptionsFromContext(req.Context())
+	if IsAnonymous(creds) {
+		return
+	}
+
+	sdkOpts := []s3v2.Option{
+		s3v2.WithPathStyle(pathStyleFromRequest(req)),
+		s3v2.WithBucket(bucketNameFromParams(req.Params)),
+		s3v2.WithBody(req.GetBody()),
+		// credentials that turn out to be anonymous, such as wrapped
+		// anonymous credentials, skip signing too
+		s3v2.WithAllowAnonymous(),
+	}
+	if req.Config.Logger != nil && req.Config.LogLevel.Matches(aws.LogDebugWithSigning) {
+		sdkOpts = append(sdkOpts, WithLogger(req.Config.Logger))
+	}
+	opts = append(append(sdkOpts, opts...), reqOpts.Options...)
+
+	var curTime time.Time
+	if curTimeFn != nil {
+		curTime = curTimeFn()
+	} else {
+		curTime = NewSigner(creds, opts...).Now()
+	}
+	opts = append(opts, s3v2.WithClock(func() time.Time { return curTime }), s3v2.WithReplaceDate())
+	if skew, ok := clockSkewFromResponse(req, curTime); ok {
+		opts = append(opts, s3v2.WithClockOffset(skew))
+	}
+
+	// in case this is a retry, ensure the previous signature is removed
+	req.HTTPRequest.Header.Del("Authorization")
+	var result s3v2.SigningResult
+	result, req.Error = NewSigner(creds, opts...).
+		SignWithContext(req.Context(), req.HTTPRequest)
+
+	// anonymous requests are left unsigned
+	if req.Error != nil || result.Signature == "" {
+		return
+	}
+
+	req.LastSignedAt = curTime
+	setSigningResult(req, result)
+}
+
+// requestCredentials returns the credentials signing the SDK request, those
+// of its RequestOptions or else of its config
+func requestCredentials(req *request.Request) *credentials.Credentials {
+	if reqOpts, _ := RequestOptionsFromContext(req.Context()); reqOpts.Credentials != nil {
+		return reqOpts.Credentials
+	}
+	return req.Config.Credentials
+}
+
+// RequestOptions override how the SDK requests made with a context are
+// signed, so a single service client can sign calls for different tenants
+type RequestOptions struct {
+	// Credentials sign the requests instead of the credentials of the
+	// config, if set
+	Credentials *credentials.Credentials
+	// Options are applied after the options of the handler, such as
+	// s3v2.WithPathStyle or s3v2.WithBucket
+	Options []s3v2.Option
+}
+
+type requestOptionsKey struct{}
+
+// WithRequestOptions returns a copy of ctx that signs the SDK requests made
+// with it with opts:
+//
+//	ctx = awsv1.WithRequestOptions(ctx, awsv1.RequestOptions{Credentials: tenantCreds})
+//	out, err := svc.GetObjectWithContext(ctx, input)
+func WithRequestOptions(ctx context.Context, opts RequestOptions) context.Context {
+	return context.WithValue(ctx, requestOptionsKey{}, opts)
+}
+
+// RequestOptionsFromContext returns the options of WithRequestOptions of
+// ctx, or false if it has none
+func RequestOptionsFromContext(ctx context.Context) (RequestOptions, bool) {
+	opts, ok := ctx.Value(requestOptionsKey{}).(RequestOptions)
+	return opts, ok
+}
+
+// minClockSkew is the smallest difference between the server and local
+// clocks that is corrected for on retries
+const minClockSkew = time.Minute
+
+// clockSkewFromResponse returns the offset between the server and local
+// clocks when the previous attempt of a retried request was rejected with
+// a 403, using the Date of the server's response
+func clockSkewFromResponse(req *request.Request, now time.Time) (time.Duration, bool) {
+	if req.RetryCount == 0 || req.HTTPResponse == nil ||
+		req.HTTPResponse.StatusCode != http.StatusForbidden {
+		return 0, false
+	}
+
+	serverTime, err := http.ParseTime(req.HTTPResponse.Header.Get("Date"))
+	if err != nil {
+		return 0, false
+	}
+
+	skew := serverTime.Sub(now)
+	if skew > -minClockSkew && skew < minClockSkew {
+		return 0, false
+	}
+	return skew, true
+}
+
+type signHandlerRanKey struct{}
+
+// markSignHandlerRan records that the V2 signer ran for the SDK request,
+// whether or not it signed it
+func markSignHandlerRan(req *request.Request) {
+	if ran, _ := req.Context().Value(signHandlerRanKey{}).(bool); !ran {
+		req.SetContext(context.WithValue(req.Context(), signHandlerRanKey{}, true))
+	}
+}
+
+type signingResultKey struct{}
+
+// setSigningResult stores the values computed to sign the SDK request in
+// its context. Retries update the stored values in place.
+func setSigningResult(req *request.Request, result s3v2.SigningResult) {
+	if stored, ok := req.Context().Value(signingResultKey{}).(*s3v2.SigningResult); ok {
+		*stored = result
+		return
+	}
+	req.SetContext(context.WithValue(req.Context(), signingResultKey{}, &result))
+}
+
+// SigningResultFromRequest returns the values computed the last time the
+// SDK request was signed by SignSDKRequest, or false if it was not signed
+func SigningResultFromRequest(req *request.Request) (s3v2.SigningResult, bool) {
+	stored, ok := req.Context().Value(signingResultKey{}).(*s3v2.SigningResult)
+	if !ok {
+		return s3v2.SigningResult{}, false
+	}
+	return *stored, true
+}
+
+// pathStyleFromRequest reports whether the SDK request is path style. This
+// is either forced by the config, or detected from the endpoint when the
+// SDK could not move the bucket into the host.
+func pathStyleFromRequest(req *request.Request) bool {
+	if aws.BoolValue(req.Config.S3ForcePathStyle) {
+		return true
+	}
+
+	host := req.HTTPRequest.Host
+	if host == "" {
+		host = req.HTTPRequest.URL.Host
+	}
+	if isIPHost(host) || isSingleLabelHost(host) {
+		return true
+	}
+
+	bucket := bucketNameFromParams(req.Params)
+	return bucket != "" && !strings.HasPrefix(host, bucket+".")
+}
+
+// isIPHost reports whether the host (with or without a port) is an
+// IPv4 or IPv6 address literal
+func isIPHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	// strip any IPv6 zone identifier
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host) != nil
+}
+
+// isSingleLabelHost reports whether the host (with or without a port) has
+// no domain components, such as "localhost:9000", which can never be a
+// virtual host for a bucket
+func isSingleLabelHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host != "" && !strings.Contains(host, ".") && !strings.Contains(host, ":")
+}
+
+// bucketNameFromParams returns the Bucket member of the SDK operation
+// input parameters, or "" if there is none
+func bucketNameFromParams(params interface{}) string {
+	if params == nil {
+		return ""
+	}
+	values, err := awsutil.ValuesAtPath(params, "Bucket")
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	if bucket, ok := values[0].(*string); ok {
+		return aws.StringValue(bucket)
+	}
+	return ""
+}
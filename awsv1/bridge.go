@@ -0,0 +1,31 @@
+package awsv1
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/benmcclelland/s3v2"
+)
+
+// NewV4Bridge returns a s3v2.Resigner that verifies V2 signed requests,
+// from legacy clients, with verifier and forwards them to backend signed
+// with SigV4 for S3 in region, so they can reach services that no longer
+// accept the V2 signature. Requests are forwarded path style, and the body
+// is streamed as an unsigned payload rather than read to hash it. Requests
+// are signed at the time of clock, or s3v2.SystemClock if it is nil.
+func NewV4Bridge(verifier *s3v2.Verifier, backend *url.URL, creds *credentials.Credentials, region string, clock s3v2.Clock) *s3v2.Resigner {
+	if clock == nil {
+		clock = s3v2.SystemClock
+	}
+	signer := v4.NewSigner(creds, func(s *v4.Signer) {
+		s.DisableURIPathEscaping = true
+		s.DisableRequestBodyOverwrite = true
+		s.UnsignedPayload = true
+	})
+	return s3v2.NewResignerFunc(verifier, backend, func(out *http.Request) error {
+		_, err := signer.Sign(out, nil, "s3", region, clock.Now())
+		return err
+	})
+}
@@ -0,0 +1,70 @@
+package awsv1
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/benmcclelland/s3v2"
+)
+
+// NewS3Client returns an S3 service client that signs requests with the
+// V2 signature.
+//
+// Requests are not signed when the config uses
+// credentials.AnonymousCredentials. When S3ForcePathStyle is not set and
+// the endpoint is an IP address or a single label host, such as
+// "http://localhost:9000", path style is used since the bucket can't be
+// put in the host.
+//
+// The session is created like session.Must, so NewS3Client panics if the
+// shared config can't be loaded.
+func NewS3Client(cfg *aws.Config, opts ...s3v2.Option) *s3.S3 {
+	if cfg == nil {
+		cfg = &aws.Config{}
+	}
+	cfg = cfg.Copy()
+
+	if cfg.S3ForcePathStyle == nil && cfg.Endpoint != nil &&
+		endpointRequiresPathStyle(*cfg.Endpoint) {
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	svc := s3.New(session.Must(session.NewSession(cfg)))
+	SwapSigner(svc.Client, opts...)
+	return svc
+}
+
+// endpointRequiresPathStyle reports whether the endpoint can't be used
+// with virtual host style buckets
+func endpointRequiresPathStyle(endpoint string) bool {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	return isIPHost(u.Host) || isSingleLabelHost(u.Host)
+}
+
+// ProfileConfig returns the SDK config of clients of the service of the
+// profile, for use with NewS3Client and the Option of the profile:
+//
+//	cfg := awsv1.ProfileConfig(p).WithCredentials(creds)
+//	svc := awsv1.NewS3Client(cfg, p.Option())
+func ProfileConfig(p s3v2.Profile) *aws.Config {
+	cfg := &aws.Config{}
+	if p.Endpoint != "" {
+		cfg.Endpoint = aws.String(p.Endpoint)
+	}
+	if p.Region != "" {
+		cfg.Region = aws.String(p.Region)
+	}
+	if p.PathStyle {
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	return cfg
+}
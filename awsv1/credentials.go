@@ -0,0 +1,98 @@
+package awsv1
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/benmcclelland/s3v2"
+)
+
+// Credentials returns a s3v2.CredentialsProvider of the keys of creds.
+// Temporary credentials are refreshed ahead of their expiry by signers
+// with s3v2.WithCredentialRefreshWindow.
+func Credentials(creds *credentials.Credentials) s3v2.CredentialsProvider {
+	return &credentialsProvider{creds: creds}
+}
+
+type credentialsProvider struct {
+	creds *credentials.Credentials
+}
+
+func (p *credentialsProvider) Retrieve(ctx context.Context) (string, string, string, error) {
+	if IsAnonymous(p.creds) {
+		return "", "", "", nil
+	}
+	value, err := p.creds.GetWithContext(ctx)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == credentials.ErrStaticCredentialsEmpty.Code() {
+		// static credentials without keys are anonymous
+		return "", "", "", nil
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+	return value.AccessKeyID, value.SecretAccessKey, value.SessionToken, nil
+}
+
+func (p *credentialsProvider) ExpiresAt() (time.Time, bool) {
+	if IsAnonymous(p.creds) {
+		return time.Time{}, false
+	}
+	expiresAt, err := p.creds.ExpiresAt()
+	if err != nil {
+		// the provider does not expire
+		return time.Time{}, false
+	}
+	return expiresAt, true
+}
+
+func (p *credentialsProvider) Expire() {
+	if !IsAnonymous(p.creds) {
+		p.creds.Expire()
+	}
+}
+
+// IsAnonymous reports whether creds are anonymous, nil or
+// credentials.AnonymousCredentials, without retrieving them. Credentials
+// that retrieve an empty access key id are also treated as anonymous when
+// signing.
+func IsAnonymous(creds *credentials.Credentials) bool {
+	return creds == nil || creds == credentials.AnonymousCredentials
+}
+
+// NewSigner returns a s3v2.Signer that signs requests with creds,
+// configured by opts
+func NewSigner(creds *credentials.Credentials, opts ...s3v2.Option) *s3v2.Signer {
+	return s3v2.NewSigner(Credentials(creds), opts...)
+}
+
+// CredentialResolverFunc is a s3v2.CredentialResolver function returning
+// the credentials of aws-sdk-go, or nil to sign with the credentials of the
+// signer
+type CredentialResolverFunc func(bucket, host string) *credentials.Credentials
+
+// CredentialsFor implements s3v2.CredentialResolver
+func (f CredentialResolverFunc) CredentialsFor(bucket, host string) s3v2.CredentialsProvider {
+	return resolved(f(bucket, host))
+}
+
+// BucketCredentials is a s3v2.CredentialResolver of the credentials of
+// each bucket
+type BucketCredentials map[string]*credentials.Credentials
+
+// CredentialsFor implements s3v2.CredentialResolver
+func (m BucketCredentials) CredentialsFor(bucket, host string) s3v2.CredentialsProvider {
+	return resolved(m[bucket])
+}
+
+// resolved returns the provider of the resolved creds, or nil if there are
+// none so the credentials of the signer are used
+func resolved(creds *credentials.Credentials) s3v2.CredentialsProvider {
+	if creds == nil {
+		return nil
+	}
+	return Credentials(creds)
+}
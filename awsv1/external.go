@@ -0,0 +1,81 @@
+package awsv1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/benmcclelland/s3v2"
+)
+
+// ExternalProviderName is the ProviderName of credentials fetched by an
+// ExternalProvider
+const ExternalProviderName = "ExternalProvider"
+
+// ExternalProvider is a credentials.Provider of credentials fetched from
+// an external secret store, such as Vault, and fetched again after TTL so
+// long running services pick up rotated keys without restarting
+type ExternalProvider struct {
+	credentials.Expiry
+
+	// Fetch fetches the current access key id and secret from the store
+	Fetch func(ctx context.Context) (credentials.Value, error)
+	// TTL is how long fetched credentials are used before they are
+	// fetched again, forever if 0
+	TTL time.Duration
+	// ExpiryWindow fetches the credentials again this long before the
+	// TTL passes, so requests are not signed with keys about to rotate
+	ExpiryWindow time.Duration
+	// Clock tells when the TTL passes, s3v2.SystemClock if nil
+	Clock s3v2.Clock
+}
+
+// NewExternalCredentials returns credentials fetched by fetch, and fetched
+// again every ttl by clock, or s3v2.SystemClock if it is nil
+func NewExternalCredentials(fetch func(ctx context.Context) (credentials.Value, error), ttl time.Duration, clock s3v2.Clock) *credentials.Credentials {
+	return credentials.NewCredentials(&ExternalProvider{Fetch: fetch, TTL: ttl, Clock: clock})
+}
+
+// Retrieve implements credentials.Provider
+func (p *ExternalProvider) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithContext(context.Background())
+}
+
+// RetrieveWithContext implements credentials.ProviderWithContext
+func (p *ExternalProvider) RetrieveWithContext(ctx credentials.Context) (credentials.Value, error) {
+	value, err := p.Fetch(ctx)
+	if err != nil {
+		return credentials.Value{ProviderName: ExternalProviderName},
+			fmt.Errorf("s3v2: fetching credentials: %w", err)
+	}
+	if value.ProviderName == "" {
+		value.ProviderName = ExternalProviderName
+	}
+
+	if p.TTL > 0 {
+		p.SetExpiration(p.now().Add(p.TTL), p.ExpiryWindow)
+	}
+	return value, nil
+}
+
+// IsExpired implements credentials.Provider. Credentials without a TTL
+// never expire.
+func (p *ExternalProvider) IsExpired() bool {
+	if p.TTL <= 0 {
+		return false
+	}
+	return p.ExpiresAt().Before(p.now())
+}
+
+// now returns the current time of the clock, or of CurrentTime of the
+// Expiry if only it is set
+func (p *ExternalProvider) now() time.Time {
+	if p.Clock != nil {
+		return p.Clock.Now()
+	}
+	if p.CurrentTime != nil {
+		return p.CurrentTime()
+	}
+	return s3v2.SystemClock.Now()
+}
@@ -0,0 +1,21 @@
+package awsv1
+
+import (
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/benmcclelland/s3v2"
+)
+
+// NewResigner returns a s3v2.Resigner that verifies requests with verifier
+// and forwards them to backend signed with creds, like s3v2.NewResigner
+func NewResigner(verifier *s3v2.Verifier, backend *url.URL, creds *credentials.Credentials, opts ...s3v2.Option) *s3v2.Resigner {
+	return s3v2.NewResigner(verifier, backend, Credentials(creds), opts...)
+}
+
+// NewDowngrader returns a s3v2.Downgrader that authenticates requests with
+// validate and forwards them to backend signed with creds, like
+// s3v2.NewDowngrader
+func NewDowngrader(validate s3v2.V4Validator, backend *url.URL, creds *credentials.Credentials, opts ...s3v2.Option) *s3v2.Downgrader {
+	return s3v2.NewDowngrader(validate, backend, Credentials(creds), opts...)
+}
@@ -0,0 +1,52 @@
+package awsv1
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/benmcclelland/s3v2"
+)
+
+// WithLogger logs the string to sign and signature of every request to
+// logger, the way the aws-sdk-go signers log them. It replaces the logger
+// of s3v2.WithSlog.
+func WithLogger(logger aws.Logger) s3v2.Option {
+	return s3v2.WithSlog(slog.New(signingLogHandler{logger: logger}))
+}
+
+const logSignInfoMsg = `DEBUG: Request Signature:
+---[ STRING TO SIGN ]--------------------------------
+%s
+---[ SIGNATURE (%s) ]-------------------------------
+%s
+-----------------------------------------------------`
+
+// signingLogHandler is a slog.Handler logging the records of signed
+// requests to an aws.Logger
+type signingLogHandler struct {
+	logger aws.Logger
+}
+
+func (h signingLogHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h signingLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var stringToSign, method, signature string
+	r.Attrs(func(attr slog.Attr) bool {
+		switch attr.Key {
+		case "string_to_sign":
+			stringToSign = attr.Value.String()
+		case "signature_method":
+			method = attr.Value.String()
+		case "signature":
+			signature = attr.Value.String()
+		}
+		return true
+	})
+	h.logger.Log(fmt.Sprintf(logSignInfoMsg, stringToSign, method, signature))
+	return nil
+}
+
+func (h signingLogHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h signingLogHandler) WithGroup(string) slog.Handler      { return h }
@@ -0,0 +1,37 @@
+package awsv1
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLogger(t *testing.T) {
+	assert := assert.New(t)
+
+	var logged []string
+	svc := newTestS3Client(&aws.Config{
+		LogLevel: aws.LogLevel(aws.LogDebugWithSigning),
+		Logger: aws.LoggerFunc(func(args ...interface{}) {
+			logged = append(logged, fmt.Sprint(args...))
+		}),
+	})
+	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String("johnsmith"),
+		Key:    aws.String("photos/puppy.jpg"),
+	})
+	assert.NoError(req.Build())
+	logged = nil
+
+	SignSDKRequestWithCurrentTime(req, func() time.Time { return testSigningTime })
+	assert.NoError(req.Error)
+	if assert.Len(logged, 1) {
+		assert.Contains(logged[0], "GET\n\n\nTue, 27 Mar 2007 19:36:42 +0000\n/johnsmith/photos/puppy.jpg")
+		assert.Contains(logged[0], "---[ SIGNATURE (HmacSHA1) ]")
+		assert.Contains(logged[0], "bWq2s1WEIj+Ydj0vQ697zp+IXMU=")
+	}
+}
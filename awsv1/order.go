@@ -0,0 +1,90 @@
+package awsv1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/benmcclelland/s3v2"
+)
+
+// ErrHandlerOrder is the error of SDK requests whose handlers run the V2
+// signer out of order, which servers would otherwise reject with a
+// SignatureDoesNotMatch
+var ErrHandlerOrder = errors.New("s3v2: V2 signer runs out of order")
+
+// CheckSignedHandler is a named send handler that fails requests that are
+// not signed by the V2 signer, or whose Content-MD5 or Content-Type was
+// changed after signing, with an ErrHandlerOrder error
+var CheckSignedHandler = request.NamedHandler{
+	Name: "v2.CheckSignedHandler",
+	Fn:   checkSigned,
+}
+
+// InstallSigner swaps the V2 signer in for the V4 signer of the service
+// client like SwapSigner, and also checks at runtime that every request is
+// signed after the handler setting its Content-Length, and that nothing
+// changes what was signed before it is sent. Requests whose handlers are
+// misordered fail with an ErrHandlerOrder error naming the problem.
+func InstallSigner(c *client.Client, opts ...s3v2.Option) {
+	// the content length handler records that it ran, for the signer
+	contentLength := corehandlers.BuildContentLengthHandler
+	guarded := c.Handlers.Sign.Swap(contentLength.Name, request.NamedHandler{
+		Name: contentLength.Name,
+		Fn: func(r *request.Request) {
+			contentLength.Fn(r)
+			r.SetContext(context.WithValue(r.Context(), contentLengthBuiltKey{}, true))
+		},
+	})
+
+	handler := BuildNamedHandler(SignRequestHandler.Name, opts...)
+	sign := handler.Fn
+	handler.Fn = func(r *request.Request) {
+		if built, _ := r.Context().Value(contentLengthBuiltKey{}).(bool); guarded && !built {
+			r.Error = fmt.Errorf("%w: %s runs before %s", ErrHandlerOrder, SignRequestHandler.Name,
+				contentLength.Name)
+			return
+		}
+		sign(r)
+	}
+	swapSignHandler(c, handler)
+
+	c.Handlers.Send.RemoveByName(CheckSignedHandler.Name)
+	c.Handlers.Send.PushFrontNamed(CheckSignedHandler)
+}
+
+type contentLengthBuiltKey struct{}
+
+// checkSigned fails the SDK request if it is about to be sent unsigned, or
+// with a Content-MD5 or Content-Type other than the one signed. Requests
+// the signer left unsigned, since their credentials are anonymous, are
+// sent as they are.
+func checkSigned(r *request.Request) {
+	if ran, _ := r.Context().Value(signHandlerRanKey{}).(bool); !ran {
+		r.Error = fmt.Errorf("%w: request is sent without running %s", ErrHandlerOrder,
+			SignRequestHandler.Name)
+		return
+	}
+	result, ok := SigningResultFromRequest(r)
+	if !ok {
+		return
+	}
+
+	// the string to sign starts with the method, Content-MD5 and
+	// Content-Type of the signed request
+	fields := strings.SplitN(result.StringToSign, "\n", 4)
+	if len(fields) < 4 {
+		return
+	}
+	for i, name := range []string{"Content-Md5", "Content-Type"} {
+		if value := r.HTTPRequest.Header.Get(name); value != fields[i+1] {
+			r.Error = fmt.Errorf("%w: %s changed from %q to %q after %s", ErrHandlerOrder,
+				name, fields[i+1], value, SignRequestHandler.Name)
+			return
+		}
+	}
+}
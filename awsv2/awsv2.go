@@ -0,0 +1,39 @@
+// Package awsv2 adapts the credentials of aws-sdk-go-v2 to the signers of
+// package s3v2:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	signer := s3v2.NewSigner(awsv2.Credentials(cfg.Credentials))
+package awsv2
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/benmcclelland/s3v2"
+)
+
+// Credentials returns a s3v2.CredentialsProvider of the keys of provider.
+// The provider is wrapped in an aws.CredentialsCache unless it is one, so
+// keys are not fetched again for every request signed.
+func Credentials(provider aws.CredentialsProvider) s3v2.CredentialsProvider {
+	if _, ok := provider.(*aws.CredentialsCache); !ok && provider != nil {
+		provider = aws.NewCredentialsCache(provider)
+	}
+	return credentialsProvider{provider: provider}
+}
+
+type credentialsProvider struct {
+	provider aws.CredentialsProvider
+}
+
+func (p credentialsProvider) Retrieve(ctx context.Context) (string, string, string, error) {
+	if p.provider == nil {
+		// no provider is anonymous
+		return "", "", "", nil
+	}
+	creds, err := p.provider.Retrieve(ctx)
+	if err != nil {
+		return "", "", "", err
+	}
+	return creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, nil
+}
@@ -0,0 +1,48 @@
+package s3v2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresignBatch(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Unix(1175139620, 0)
+	signer := newTestSigner(WithClock(func() time.Time { return now }))
+	keys := []ObjectRef{
+		{Bucket: "johnsmith", Key: "photos/puppy.jpg"},
+		{Bucket: "johnsmith", Key: "photos/puppy dog.jpg", VersionID: "3"},
+		{Bucket: "my.bucket", Key: "report.csv", Endpoint: "http://10.0.0.1:9000"},
+	}
+	urls, err := signer.PresignBatch(keys, time.Hour)
+	assert.NoError(err)
+	assert.Len(urls, len(keys))
+
+	// the URLs are those of PresignObject
+	for i, ref := range keys {
+		p := signer.PresignObject(ref.Bucket, ref.Key).ExpiresAt(now.Add(time.Hour))
+		if ref.VersionID != "" {
+			p.VersionID(ref.VersionID)
+		}
+		if ref.Endpoint != "" {
+			p.Endpoint(ref.Endpoint)
+		}
+		u, err := p.URL()
+		assert.NoError(err)
+		assert.Equal(u.String(), urls[i])
+
+		r := httptest.NewRequest(http.MethodGet, urls[i], nil)
+		_, err = NewVerifier(testSecretLookup, WithVerifierClock(func() time.Time { return now })).Verify(r)
+		assert.NoError(err, urls[i])
+	}
+
+	_, err = signer.PresignBatch(keys, -time.Hour)
+	assert.ErrorIs(err, ErrInvalidExpiry)
+	_, err = signer.PresignBatch([]ObjectRef{{Key: "photos/puppy.jpg"}}, time.Hour)
+	assert.Error(err)
+}
@@ -0,0 +1,153 @@
+package s3v2
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VerifierCache is an in-memory LRU cache of the credentials a Verifier
+// looks up, and of the signatures it verified, so bursts of identical
+// requests skip the Keyring lookup and the HMAC. Cached signatures are
+// only valid within the clock skew window of the request, or until a
+// presigned request expires, so signatures of verifiers without a clock
+// skew window are never cached. Credentials removed from the Keyring, such
+// as revoked keys, stay valid until their entry is older than the TTL,
+// which is at most MaxVerifierCacheTTL, or until they are invalidated.
+type VerifierCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type cacheEntry struct {
+	key     string
+	cred    Credential
+	expires time.Time
+}
+
+// MaxVerifierCacheTTL is the longest a VerifierCache keeps its entries,
+// bounding how long a key removed from the Keyring is still accepted
+const MaxVerifierCacheTTL = 5 * time.Minute
+
+// NewVerifierCache returns a VerifierCache of up to size entries, each
+// kept for at most ttl, or MaxVerifierCacheTTL if it is longer. A size or
+// ttl of 0 or less caches nothing, so every request is looked up in the
+// Keyring and verified.
+func NewVerifierCache(size int, ttl time.Duration) *VerifierCache {
+	ttl = min(ttl, MaxVerifierCacheTTL)
+	return &VerifierCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// WithVerifierCache caches the credentials looked up, and the signatures
+// verified, in cache. A cache can be shared by verifiers with the same
+// Keyring.
+func WithVerifierCache(cache *VerifierCache) VerifierOption {
+	return func(v *Verifier) {
+		v.cache = cache
+	}
+}
+
+// Invalidate removes the credential of the access key id, and the
+// signatures it verified, from the cache, such as when the key is revoked
+func (c *VerifierCache) Invalidate(accessKeyID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := "s\x00" + accessKeyID + "\x00"
+	for key, elem := range c.entries {
+		if key == credentialKey(accessKeyID) || strings.HasPrefix(key, prefix) {
+			c.remove(elem)
+		}
+	}
+}
+
+func credentialKey(accessKeyID string) string {
+	return "k\x00" + accessKeyID
+}
+
+func signatureKey(accessKeyID, stringToSign, signature string) string {
+	return "s\x00" + accessKeyID + "\x00" + signature + "\x00" + stringToSign
+}
+
+// lookup returns the credential of the access key id from the cache, or
+// from keyring when it is not cached. Now is the time of the verifier.
+func (c *VerifierCache) lookup(ctx context.Context, keyring Keyring, accessKeyID string, now time.Time) (Credential, error) {
+	key := credentialKey(accessKeyID)
+	if cred, ok := c.get(key, now); ok {
+		return cred, nil
+	}
+	cred, err := keyring.Lookup(ctx, accessKeyID)
+	if err != nil {
+		return Credential{}, err
+	}
+	c.add(key, cred, now, time.Time{})
+	return cred, nil
+}
+
+// verified returns the credential that signed the string to sign with
+// signature, if it was verified before
+func (c *VerifierCache) verified(accessKeyID, stringToSign, signature string, now time.Time) (Credential, bool) {
+	return c.get(signatureKey(accessKeyID, stringToSign, signature), now)
+}
+
+// addVerified caches the signature of the string to sign, verified with
+// cred, until the request is no longer valid
+func (c *VerifierCache) addVerified(cred Credential, stringToSign, signature string, now, validUntil time.Time) {
+	c.add(signatureKey(cred.AccessKeyID, stringToSign, signature), cred, now, validUntil)
+}
+
+func (c *VerifierCache) get(key string, now time.Time) (Credential, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return Credential{}, false
+	}
+	entry := elem.Value.(cacheEntry)
+	if !now.Before(entry.expires) {
+		c.remove(elem)
+		return Credential{}, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.cred, true
+}
+
+// add caches cred under key until the TTL passes, or validUntil if it is
+// set and sooner
+func (c *VerifierCache) add(key string, cred Credential, now, validUntil time.Time) {
+	if c.size <= 0 || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := now.Add(c.ttl)
+	if !validUntil.IsZero() && validUntil.Before(expires) {
+		expires = validUntil
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(cacheEntry{key: key, cred: cred, expires: expires})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *VerifierCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(cacheEntry).key)
+}
@@ -0,0 +1,21 @@
+package s3v2
+
+import "time"
+
+// Clock tells the current time. Signing reads it to date requests and
+// check presign expiries, and verification to check clock skew and
+// expiries, so tests and simulations can control time.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function returning the current time to a Clock
+type ClockFunc func() time.Time
+
+// Now implements Clock
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the local clock, used when no other clock is set
+var SystemClock Clock = ClockFunc(time.Now)
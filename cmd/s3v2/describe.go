@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/benmcclelland/s3v2"
+)
+
+func runDescribe(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("describe", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: s3v2 describe [flags] <presigned url>")
+		fs.PrintDefaults()
+	}
+	curl := fs.Bool("curl", false, "print a curl command that sends the presigned request")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	d, err := s3v2.DescribePresignedURL(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, "s3v2: describe:", err)
+		return 1
+	}
+	fmt.Fprint(stdout, d)
+	if d.Expired(time.Now()) {
+		fmt.Fprintln(stdout, "\nThe URL has expired.")
+	}
+
+	if *curl {
+		req, err := http.NewRequest(http.MethodGet, fs.Arg(0), nil)
+		if err != nil {
+			fmt.Fprintln(stderr, "s3v2:", err)
+			return 1
+		}
+		cmd, err := s3v2.CurlCommand(req)
+		if err != nil {
+			fmt.Fprintln(stderr, "s3v2: curl:", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "\n%s\n", cmd)
+	}
+	return 0
+}
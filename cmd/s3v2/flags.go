@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// credentialFlags select the credentials to sign with. Keys given as flags
+// take precedence over the AWS_* environment variables, which take
+// precedence over the shared credentials file.
+type credentialFlags struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	profile      string
+}
+
+func (c *credentialFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.accessKey, "access-key", "", "access key id")
+	fs.StringVar(&c.secretKey, "secret-key", "", "secret access key")
+	fs.StringVar(&c.sessionToken, "session-token", "", "session token")
+	fs.StringVar(&c.profile, "profile", "", "shared credentials profile")
+}
+
+func (c *credentialFlags) credentials() *credentials.Credentials {
+	var providers []credentials.Provider
+	if c.accessKey != "" || c.secretKey != "" {
+		providers = append(providers, &credentials.StaticProvider{Value: credentials.Value{
+			AccessKeyID:     c.accessKey,
+			SecretAccessKey: c.secretKey,
+			SessionToken:    c.sessionToken,
+		}})
+	}
+	providers = append(providers,
+		&credentials.EnvProvider{},
+		&credentials.SharedCredentialsProvider{Profile: c.profile})
+	return credentials.NewChainCredentials(providers)
+}
+
+// headerFlags collects repeated "Name: value" header flags
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	var headers []string
+	for name, values := range h {
+		for _, value := range values {
+			headers = append(headers, name+": "+value)
+		}
+	}
+	return strings.Join(headers, ", ")
+}
+
+func (h headerFlags) Set(header string) error {
+	i := strings.Index(header, ":")
+	if i <= 0 {
+		return fmt.Errorf("header %q is not of the form \"Name: value\"", header)
+	}
+	http.Header(h).Add(strings.TrimSpace(header[:i]), strings.TrimSpace(header[i+1:]))
+	return nil
+}
@@ -0,0 +1,49 @@
+// Command s3v2 signs S3 requests with the V2 signature, for scripting
+// against S3 compatible services without writing Go.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: s3v2 <command> [flags]
+
+commands:
+  sign     sign a request and print the Authorization header
+  presign  print a presigned URL
+  verify   check the signature of a captured request
+  describe decode a presigned URL
+
+Run "s3v2 <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command in args and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "sign":
+		return runSign(args[1:], stdout, stderr)
+	case "presign":
+		return runPresign(args[1:], stdout, stderr)
+	case "verify":
+		return runVerify(args[1:], stdout, stderr)
+	case "describe":
+		return runDescribe(args[1:], stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "s3v2: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/benmcclelland/s3v2"
+	"github.com/benmcclelland/s3v2/awsv1"
+)
+
+// responseOverrides are the query parameters overriding the headers of GET
+// responses that can be set when presigning
+var responseOverrides = []string{
+	"response-cache-control",
+	"response-content-disposition",
+	"response-content-encoding",
+	"response-content-language",
+	"response-content-type",
+	"response-expires",
+}
+
+func runPresign(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("presign", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: s3v2 presign [flags] <s3://bucket/key | url>")
+		fs.PrintDefaults()
+	}
+
+	var creds credentialFlags
+	creds.register(fs)
+	headers := make(headerFlags)
+	fs.Var(headers, "H", "header \"Name: value\" the URL must be sent with (repeatable)")
+	method := fs.String("X", http.MethodGet, "request method")
+	expires := fs.Duration("expires", time.Hour, "how long the URL is valid for")
+	endpoint := fs.String("endpoint", "https://s3.amazonaws.com", "endpoint of s3:// URLs")
+	pathStyle := fs.Bool("path-style", false, "address the bucket of s3:// URLs in the path")
+	overrides := make(map[string]*string)
+	for _, name := range responseOverrides {
+		overrides[name] = fs.String(name, "", "override the "+
+			strings.TrimPrefix(name, "response-")+" header of the response")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	var opts []s3v2.Option
+	target := fs.Arg(0)
+	if strings.HasPrefix(target, "s3://") {
+		bucket, u, err := resolveS3URL(target, *endpoint, *pathStyle)
+		if err != nil {
+			fmt.Fprintln(stderr, "s3v2:", err)
+			return 1
+		}
+		target = u.String()
+		opts = append(opts, s3v2.WithBucket(bucket))
+	}
+	if *pathStyle {
+		opts = append(opts, s3v2.WithPathStyle(true))
+	}
+
+	req, err := http.NewRequest(*method, target, nil)
+	if err != nil {
+		fmt.Fprintln(stderr, "s3v2:", err)
+		return 1
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	query := req.URL.Query()
+	for _, name := range responseOverrides {
+		if value := *overrides[name]; value != "" {
+			query.Set(name, value)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+
+	signer := awsv1.NewSigner(creds.credentials(), opts...)
+	u, err := signer.Presign(req, time.Now().Add(*expires))
+	if err != nil {
+		fmt.Fprintln(stderr, "s3v2: presign:", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, u)
+	return 0
+}
+
+// resolveS3URL returns the bucket of an s3://bucket/key URL, and the URL of
+// the object at endpoint
+func resolveS3URL(s3URL, endpoint string, pathStyle bool) (string, *url.URL, error) {
+	src, err := url.Parse(s3URL)
+	if err != nil {
+		return "", nil, err
+	}
+	if src.Host == "" {
+		return "", nil, fmt.Errorf("%s: missing bucket", s3URL)
+	}
+	u, err := s3v2.ObjectURL(endpoint, src.Host, strings.TrimPrefix(src.Path, "/"), pathStyle)
+	if err != nil {
+		return "", nil, err
+	}
+	return src.Host, u, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benmcclelland/s3v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresign(t *testing.T) {
+	assert := assert.New(t)
+
+	lookup := s3v2.SecretLookup(func(accessKeyID string) (string, error) {
+		return testSecretKey, nil
+	})
+	server := httptest.NewServer(s3v2.RequireAuth(lookup, http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.URL.Path)
+		})))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"presign",
+		"-access-key", testAccessKey,
+		"-secret-key", testSecretKey,
+		"-endpoint", server.URL,
+		"-expires", "5m",
+		"-response-content-disposition", "attachment; filename=report.csv",
+		"s3://bucket/reports/2007.csv",
+	}, &stdout, &stderr)
+	assert.Equal(0, code, stderr.String())
+
+	u := strings.TrimSpace(stdout.String())
+	assert.Contains(u, "response-content-disposition=attachment%3B+filename%3Dreport.csv")
+
+	resp, err := http.Get(u)
+	assert.NoError(err)
+	defer resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func TestResolveS3URL(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		s3URL, endpoint string
+		pathStyle       bool
+		expected        string
+	}{
+		{"s3://bucket/key", "https://s3.amazonaws.com", false, "https://bucket.s3.amazonaws.com/key"},
+		{"s3://bucket/a/b c", "https://s3.amazonaws.com", false, "https://bucket.s3.amazonaws.com/a/b%20c"},
+		{"s3://bucket/key", "https://s3.amazonaws.com", true, "https://s3.amazonaws.com/bucket/key"},
+		{"s3://my.bucket/key", "https://s3.amazonaws.com", false, "https://s3.amazonaws.com/my.bucket/key"},
+		{"s3://bucket/key", "http://10.0.0.1:7480", false, "http://10.0.0.1:7480/bucket/key"},
+		{"s3://bucket/key", "http://rgw.local/s3/", true, "http://rgw.local/s3/bucket/key"},
+	}
+	for _, test := range tests {
+		bucket, u, err := resolveS3URL(test.s3URL, test.endpoint, test.pathStyle)
+		assert.NoError(err, test.s3URL)
+		assert.Equal(test.expected, u.String(), test.s3URL)
+		assert.NotEqual("", bucket)
+	}
+
+	_, _, err := resolveS3URL("s3:///key", "https://s3.amazonaws.com", false)
+	assert.Error(err)
+	_, _, err = resolveS3URL("s3://bucket/key", "s3.amazonaws.com", false)
+	assert.Error(err)
+}
+
+func TestDescribe(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"presign",
+		"-access-key", testAccessKey,
+		"-secret-key", testSecretKey,
+		"-expires", "5m",
+		"s3://johnsmith/photos/puppy.jpg",
+	}, &stdout, &stderr)
+	assert.Equal(0, code, stderr.String())
+	u := strings.TrimSpace(stdout.String())
+
+	stdout.Reset()
+	code = run([]string{"describe", "-curl", u}, &stdout, &stderr)
+	assert.Equal(0, code, stderr.String())
+	assert.Contains(stdout.String(), "access key:  "+testAccessKey+"\n")
+	assert.Contains(stdout.String(), "bucket:      johnsmith\n")
+	assert.Contains(stdout.String(), "key:         photos/puppy.jpg\n")
+	assert.NotContains(stdout.String(), "The URL has expired.")
+	assert.Contains(stdout.String(), "\ncurl ")
+
+	code = run([]string{"describe", "https://johnsmith.s3.amazonaws.com/photos/puppy.jpg"}, &stdout, &stderr)
+	assert.Equal(1, code)
+}
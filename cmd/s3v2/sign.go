@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/benmcclelland/s3v2"
+	"github.com/benmcclelland/s3v2/awsv1"
+)
+
+func runSign(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: s3v2 sign [flags] <url>")
+		fs.PrintDefaults()
+	}
+
+	var creds credentialFlags
+	creds.register(fs)
+	headers := make(headerFlags)
+	fs.Var(headers, "H", "request header \"Name: value\" (repeatable)")
+	method := fs.String("X", http.MethodGet, "request method")
+	data := fs.String("d", "", "request body")
+	pathStyle := fs.Bool("path-style", false, "sign the request as path style")
+	amzDate := fs.Bool("amz-date", false, "send the date in x-amz-date instead of Date")
+	execute := fs.Bool("execute", false, "send the signed request and print the response")
+	curl := fs.Bool("curl", false, "print a curl command that sends the signed request")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	req, err := http.NewRequest(*method, fs.Arg(0), strings.NewReader(*data))
+	if err != nil {
+		fmt.Fprintln(stderr, "s3v2:", err)
+		return 1
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	opts := []s3v2.Option{s3v2.WithPathStyle(*pathStyle)}
+	if *amzDate {
+		opts = append(opts, s3v2.WithAmzDateHeader())
+	}
+	signer := awsv1.NewSigner(creds.credentials(), opts...)
+
+	result, err := signer.Sign(req)
+	if err != nil {
+		fmt.Fprintln(stderr, "s3v2: sign:", err)
+		return 1
+	}
+
+	dateHeader := "Date"
+	if req.Header.Get("X-Amz-Date") != "" {
+		dateHeader = "X-Amz-Date"
+	}
+	fmt.Fprintf(stdout, "Authorization: %s\n", result.Authorization)
+	fmt.Fprintf(stdout, "%s: %s\n", dateHeader, result.Date)
+	fmt.Fprintf(stdout, "\nString to sign:\n%s\n", result.StringToSign)
+
+	if *curl {
+		cmd, err := s3v2.CurlCommand(req)
+		if err != nil {
+			fmt.Fprintln(stderr, "s3v2: curl:", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "\n%s\n", cmd)
+	}
+
+	if !*execute {
+		return 0
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintln(stderr, "s3v2:", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	fmt.Fprintf(stdout, "\n%s %s\n", resp.Proto, resp.Status)
+	if err := resp.Header.Write(stdout); err != nil {
+		fmt.Fprintln(stderr, "s3v2:", err)
+		return 1
+	}
+	fmt.Fprintln(stdout)
+	if _, err := io.Copy(stdout, resp.Body); err != nil {
+		fmt.Fprintln(stderr, "s3v2:", err)
+		return 1
+	}
+	if resp.StatusCode >= 300 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/benmcclelland/s3v2"
+)
+
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: s3v2 verify [flags] <request file>")
+		fmt.Fprintln(stderr, "\nThe request file is a raw HTTP request, as printed by curl -v, or a HAR file.")
+		fs.PrintDefaults()
+	}
+
+	var creds credentialFlags
+	creds.register(fs)
+	presented := fs.String("presented", "", "file with the string to sign to compare with, or an S3 error response")
+	entry := fs.Int("entry", 0, "index of the request in a HAR file")
+	pathStyle := fs.Bool("path-style", false, "verify the request as path style")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, "s3v2:", err)
+		return 1
+	}
+	req, err := parseCapturedRequest(data, *entry)
+	if err != nil {
+		fmt.Fprintln(stderr, "s3v2:", err)
+		return 1
+	}
+
+	var presentedStringToSign string
+	if *presented != "" {
+		presentedStringToSign, err = readPresented(*presented)
+		if err != nil {
+			fmt.Fprintln(stderr, "s3v2:", err)
+			return 1
+		}
+	}
+
+	value, err := creds.credentials().Get()
+	if err != nil {
+		fmt.Fprintln(stderr, "s3v2: credentials:", err)
+		return 1
+	}
+	lookup := s3v2.SecretLookup(func(string) (string, error) {
+		return value.SecretAccessKey, nil
+	})
+
+	// captured requests are checked whatever their age
+	opts := []s3v2.VerifierOption{
+		s3v2.WithMaxClockSkew(0),
+		s3v2.WithVerifierClock(func() time.Time { return time.Unix(0, 0) }),
+	}
+	if *pathStyle {
+		opts = append(opts, s3v2.WithPathStyleRequests())
+	}
+
+	_, err = s3v2.NewVerifier(lookup, opts...).Verify(req)
+	if err == nil {
+		fmt.Fprintln(stdout, "signature matches")
+		return 0
+	}
+
+	var verr *s3v2.VerifyError
+	if !errors.As(err, &verr) || verr.Code != "SignatureDoesNotMatch" {
+		fmt.Fprintln(stdout, "verification failed:", err)
+		return 1
+	}
+
+	fmt.Fprintln(stdout, "signature does not match")
+	fmt.Fprintf(stdout, "\nComputed string to sign:\n%s\n", verr.StringToSign)
+	if presentedStringToSign == "" {
+		return 1
+	}
+	fmt.Fprintf(stdout, "\nPresented string to sign:\n%s\n", presentedStringToSign)
+	fmt.Fprintf(stdout, "\n--- computed\n+++ presented\n%s", lineDiff(verr.StringToSign, presentedStringToSign))
+	fmt.Fprintf(stdout, "\n%s\n", s3v2.Diff(verr.StringToSign, presentedStringToSign))
+	return 1
+}
+
+// parseCapturedRequest parses a HAR file, or a raw HTTP request where each
+// line may be prefixed by "> " as in the output of curl -v
+func parseCapturedRequest(data []byte, entry int) (*http.Request, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseHAR(trimmed, entry)
+	}
+
+	var raw bytes.Buffer
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(line, ">") {
+			line = strings.TrimPrefix(strings.TrimPrefix(line, ">"), " ")
+		}
+		raw.WriteString(line + "\r\n")
+	}
+	raw.WriteString("\r\n")
+
+	req, err := http.ReadRequest(bufio.NewReader(&raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse request: %w", err)
+	}
+	return req, nil
+}
+
+// har is the part of an HTTP Archive needed to rebuild a request
+type har struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+func parseHAR(data []byte, entry int) (*http.Request, error) {
+	var archive har
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, fmt.Errorf("parse HAR: %w", err)
+	}
+	if entry < 0 || entry >= len(archive.Log.Entries) {
+		return nil, fmt.Errorf("HAR has no entry %d", entry)
+	}
+
+	captured := archive.Log.Entries[entry].Request
+	req, err := http.NewRequest(captured.Method, captured.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parse HAR: %w", err)
+	}
+	for _, header := range captured.Headers {
+		// HTTP/2 pseudo headers such as :authority are not headers
+		if strings.HasPrefix(header.Name, ":") {
+			continue
+		}
+		req.Header.Add(header.Name, header.Value)
+	}
+	return req, nil
+}
+
+// readPresented reads a string to sign from a file, or from the
+// StringToSign of an S3 error response
+func readPresented(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+		doc, err := s3v2.ParseErrorDocument(trimmed)
+		if err != nil {
+			return "", err
+		}
+		return doc.StringToSign, nil
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// lineDiff compares the strings line by line, marking lines only in a with
+// "-" and lines only in b with "+"
+func lineDiff(a, b string) string {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+
+	var diff strings.Builder
+	for i := 0; i < len(aLines) || i < len(bLines); i++ {
+		switch {
+		case i >= len(aLines):
+			fmt.Fprintf(&diff, "+%s\n", bLines[i])
+		case i >= len(bLines):
+			fmt.Fprintf(&diff, "-%s\n", aLines[i])
+		case aLines[i] == bLines[i]:
+			fmt.Fprintf(&diff, " %s\n", aLines[i])
+		default:
+			fmt.Fprintf(&diff, "-%s\n+%s\n", aLines[i], bLines[i])
+		}
+	}
+	return diff.String()
+}
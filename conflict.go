@@ -0,0 +1,59 @@
+package s3v2
+
+import "strings"
+
+// ConflictPolicy is what signing does with authentication a request
+// already carries that signing would not replace, such as a SigV4
+// Authorization or presigned query parameters, which servers otherwise
+// reject with confusing 403s
+type ConflictPolicy int
+
+const (
+	// ConflictReject fails signing with a *ConflictingAuthError
+	ConflictReject ConflictPolicy = iota
+	// ConflictStrip removes the conflicting authentication from the
+	// request before it is signed
+	ConflictStrip
+)
+
+// WithConflictPolicy sets what signing does with conflicting
+// authentication of requests, ConflictReject if not set
+func WithConflictPolicy(policy ConflictPolicy) Option {
+	return func(v2 *signer) {
+		v2.ConflictPolicy = policy
+	}
+}
+
+// ConflictingAuthError is returned by signing, and by Validate, when the
+// request already carries authentication signing would not replace. It
+// wraps ErrConflictingAuth.
+type ConflictingAuthError struct {
+	Reason string
+}
+
+func (e *ConflictingAuthError) Error() string {
+	return ErrConflictingAuth.Error() + ": " + e.Reason
+}
+
+func (e *ConflictingAuthError) Unwrap() error {
+	return ErrConflictingAuth
+}
+
+// checkConflictingAuth applies the conflict policy to the request
+func (v2 *signer) checkConflictingAuth() error {
+	conflict := v2.conflictingAuth()
+	if conflict == "" {
+		return nil
+	}
+	if v2.ConflictPolicy != ConflictStrip {
+		return &ConflictingAuthError{Reason: conflict}
+	}
+
+	req := v2.Request
+	if auth := req.Header.Get("Authorization"); auth != "" && !strings.HasPrefix(auth, v2.authScheme()+" ") {
+		req.Header.Del("Authorization")
+	}
+	rawQuery := stripQueryParams(req.URL.RawQuery, v4QueryParams)
+	req.URL.RawQuery = stripQueryParams(rawQuery, presignQueryParams)
+	return nil
+}
@@ -0,0 +1,374 @@
+// Package conformance runs a battery of V2 signed operations against a
+// live S3 compatible endpoint, such as AWS, MinIO or Ceph RGW, and reports
+// which canonicalization cases the endpoint rejects.
+//
+// The suite is run by the tests of this package when
+// S3V2_CONFORMANCE_ENDPOINT is set:
+//
+//	S3V2_CONFORMANCE_ENDPOINT=http://localhost:9000 \
+//	S3V2_CONFORMANCE_ACCESS_KEY=... \
+//	S3V2_CONFORMANCE_SECRET_KEY=... \
+//	S3V2_CONFORMANCE_BUCKET=s3v2-conformance \
+//	go test ./conformance -v
+//
+// S3V2_CONFORMANCE_REGION (us-east-1 by default) and
+// S3V2_CONFORMANCE_PATH_STYLE=1 are optional. The bucket is created if it
+// does not exist, and the objects written to it are deleted.
+//
+// S3V2_CONFORMANCE_PROFILE signs with the options of a profile, by its
+// name, such as "ceph-rgw" or "google-legacy".
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/benmcclelland/s3v2"
+	"github.com/benmcclelland/s3v2/awsv1"
+)
+
+// Keys are the object keys written and read back, chosen to exercise the
+// escaping of the canonical resource
+var Keys = []string{
+	"plain",
+	"unicode/français/préfère",
+	"spaces/a b c",
+	"reserved/a+b=c&d;e,f",
+	"unreserved/tilde~star*bang!",
+	"percent/100%",
+	"brackets/(a)[b]{c}",
+	"quote/it's",
+	"double//slash",
+	"dot/./segment",
+	"trailing/",
+}
+
+// Case is a signed operation run against the endpoint
+type Case struct {
+	Name string
+	Run  func(e *Env) error
+}
+
+// Env is what cases run with
+type Env struct {
+	// Client signs requests with the V2 signature
+	Client *s3.S3
+	Bucket string
+	// PathStyle is true when Client addresses buckets path style
+	PathStyle bool
+	// Options are the options Client signs with, used by cases that
+	// sign requests themselves
+	Options []s3v2.Option
+}
+
+// Result is the outcome of a case, Err is nil when it passed
+type Result struct {
+	Case string
+	Err  error
+}
+
+// Cases returns the battery of cases, in the order they are run
+func Cases() []Case {
+	cases := []Case{
+		{"ListBuckets", listBuckets},
+		{"ListObjects", listObjects},
+		{"GetBucketLocation (?location)", getBucketLocation},
+		{"GetBucketVersioning (?versioning)", getBucketVersioning},
+		{"GetBucketAcl (?acl)", getBucketACL},
+		{"PutObject metadata", putObjectMetadata},
+		{"GetObject response overrides", getObjectResponseOverrides},
+		{"PutObjectAcl (?acl)", putObjectACL},
+		{"Multipart upload (?uploads, ?partNumber&uploadId)", multipartUpload},
+		{"DeleteObjects (?delete)", deleteObjects},
+		{"Presigned GET", presignedGet},
+	}
+	for _, key := range Keys {
+		key := key
+		cases = append(cases, Case{
+			Name: fmt.Sprintf("PutObject/GetObject %q", key),
+			Run:  func(e *Env) error { return putGetObject(e, key) },
+		})
+	}
+	return cases
+}
+
+// Run prepares the bucket and runs every case against it
+func Run(e *Env) []Result {
+	if err := ensureBucket(e); err != nil {
+		return []Result{{Case: "CreateBucket", Err: err}}
+	}
+
+	var results []Result
+	for _, c := range Cases() {
+		results = append(results, Result{Case: c.Name, Err: c.Run(e)})
+	}
+	return results
+}
+
+// Report writes one line per result, and returns the number of failures
+func Report(w io.Writer, results []Result) int {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %v\n", r.Case, r.Err)
+		} else {
+			fmt.Fprintf(w, "ok   %s\n", r.Case)
+		}
+	}
+	return failed
+}
+
+func ensureBucket(e *Env) error {
+	_, err := e.Client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(e.Bucket)})
+	if err == nil {
+		return nil
+	}
+	_, err = e.Client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(e.Bucket)})
+	return err
+}
+
+func listBuckets(e *Env) error {
+	_, err := e.Client.ListBuckets(&s3.ListBucketsInput{})
+	return err
+}
+
+func listObjects(e *Env) error {
+	_, err := e.Client.ListObjects(&s3.ListObjectsInput{
+		Bucket:  aws.String(e.Bucket),
+		Prefix:  aws.String("s3v2/"),
+		Marker:  aws.String("s3v2/a b"),
+		MaxKeys: aws.Int64(5),
+	})
+	return err
+}
+
+func getBucketLocation(e *Env) error {
+	_, err := e.Client.GetBucketLocation(&s3.GetBucketLocationInput{Bucket: aws.String(e.Bucket)})
+	return err
+}
+
+func getBucketVersioning(e *Env) error {
+	_, err := e.Client.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: aws.String(e.Bucket)})
+	return err
+}
+
+func getBucketACL(e *Env) error {
+	_, err := e.Client.GetBucketAcl(&s3.GetBucketAclInput{Bucket: aws.String(e.Bucket)})
+	return err
+}
+
+// putGetObject writes key, reads it back and deletes it
+func putGetObject(e *Env, key string) error {
+	key = "s3v2/" + key
+	body := []byte("conformance " + key)
+	if _, err := e.Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(e.Bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(body),
+	}); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+	defer deleteObject(e, key)
+
+	out, err := e.Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(e.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	defer out.Body.Close()
+	got, err := io.ReadAll(out.Body)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if !bytes.Equal(got, body) {
+		return fmt.Errorf("get: read %q, wrote %q", got, body)
+	}
+	return nil
+}
+
+func deleteObject(e *Env, key string) {
+	e.Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(e.Bucket),
+		Key:    aws.String(key),
+	})
+}
+
+func putObjectMetadata(e *Env) error {
+	key := "s3v2/metadata"
+	_, err := e.Client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(e.Bucket),
+		Key:         aws.String(key),
+		Body:        strings.NewReader("metadata"),
+		ContentType: aws.String("text/plain"),
+		Metadata: map[string]*string{
+			"Reviewedby": aws.String("joe@johnsmith.net"),
+			"Folded":     aws.String("a  b"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	deleteObject(e, key)
+	return nil
+}
+
+func getObjectResponseOverrides(e *Env) error {
+	key := "s3v2/overrides"
+	if _, err := e.Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(e.Bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader("overrides"),
+	}); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+	defer deleteObject(e, key)
+
+	out, err := e.Client.GetObject(&s3.GetObjectInput{
+		Bucket:                     aws.String(e.Bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String("attachment; filename=overrides.txt"),
+		ResponseContentType:        aws.String("text/plain"),
+	})
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	out.Body.Close()
+	return nil
+}
+
+func putObjectACL(e *Env) error {
+	key := "s3v2/acl"
+	if _, err := e.Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(e.Bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader("acl"),
+	}); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+	defer deleteObject(e, key)
+
+	_, err := e.Client.PutObjectAcl(&s3.PutObjectAclInput{
+		Bucket: aws.String(e.Bucket),
+		Key:    aws.String(key),
+		ACL:    aws.String(s3.ObjectCannedACLPrivate),
+	})
+	return err
+}
+
+func multipartUpload(e *Env) error {
+	key := "s3v2/multipart upload"
+	created, err := e.Client.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket: aws.String(e.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	part, err := e.Client.UploadPart(&s3.UploadPartInput{
+		Bucket:     aws.String(e.Bucket),
+		Key:        aws.String(key),
+		UploadId:   created.UploadId,
+		PartNumber: aws.Int64(1),
+		Body:       strings.NewReader("part"),
+	})
+	if err != nil {
+		e.Client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(e.Bucket),
+			Key:      aws.String(key),
+			UploadId: created.UploadId,
+		})
+		return fmt.Errorf("upload part: %w", err)
+	}
+
+	if _, err := e.Client.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(e.Bucket),
+		Key:      aws.String(key),
+		UploadId: created.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{
+			Parts: []*s3.CompletedPart{{ETag: part.ETag, PartNumber: aws.Int64(1)}},
+		},
+	}); err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+	deleteObject(e, key)
+	return nil
+}
+
+func deleteObjects(e *Env) error {
+	key := "s3v2/delete"
+	if _, err := e.Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(e.Bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader("delete"),
+	}); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	_, err := e.Client.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(e.Bucket),
+		Delete: &s3.Delete{Objects: []*s3.ObjectIdentifier{{Key: aws.String(key)}}},
+	})
+	if err != nil {
+		deleteObject(e, key)
+	}
+	return err
+}
+
+func presignedGet(e *Env) error {
+	key := "s3v2/presigned"
+	if _, err := e.Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(e.Bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader("presigned"),
+	}); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+	defer deleteObject(e, key)
+
+	req, _ := e.Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(e.Bucket),
+		Key:    aws.String(key),
+	})
+	if err := req.Build(); err != nil {
+		return err
+	}
+
+	opts := append([]s3v2.Option{s3v2.WithPathStyle(e.PathStyle), s3v2.WithBucket(e.Bucket)},
+		e.Options...)
+	signer := awsv1.NewSigner(e.Client.Config.Credentials, opts...)
+	u, err := signer.Presign(req.HTTPRequest, time.Now().Add(5*time.Minute))
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return statusError(resp.StatusCode, body)
+	}
+	return nil
+}
+
+// statusError reports an S3 error response, with the code of its error
+// document if it has one
+func statusError(status int, body []byte) error {
+	if doc, err := s3v2.ParseErrorDocument(body); err == nil && doc.Code != "" {
+		return awserr.NewRequestFailure(awserr.New(doc.Code, doc.Message, nil), status, doc.RequestID)
+	}
+	return fmt.Errorf("status %d", status)
+}
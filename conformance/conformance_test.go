@@ -0,0 +1,106 @@
+package conformance
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/benmcclelland/s3v2"
+	"github.com/benmcclelland/s3v2/awsv1"
+	"github.com/benmcclelland/s3v2/s3v2test"
+	"github.com/stretchr/testify/assert"
+)
+
+// profiles are the profiles S3V2_CONFORMANCE_PROFILE can name
+var profiles = map[string]s3v2.Profile{
+	s3v2.ProfileCephRGW.Name:      s3v2.ProfileCephRGW,
+	s3v2.ProfileGoogleLegacy.Name: s3v2.ProfileGoogleLegacy,
+}
+
+func TestConformance(t *testing.T) {
+	endpoint := os.Getenv("S3V2_CONFORMANCE_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3V2_CONFORMANCE_ENDPOINT is not set")
+	}
+
+	region := os.Getenv("S3V2_CONFORMANCE_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	bucket := os.Getenv("S3V2_CONFORMANCE_BUCKET")
+	if bucket == "" {
+		bucket = "s3v2-conformance"
+	}
+	pathStyle := os.Getenv("S3V2_CONFORMANCE_PATH_STYLE") != ""
+
+	creds := credentials.NewStaticCredentials(
+		os.Getenv("S3V2_CONFORMANCE_ACCESS_KEY"),
+		os.Getenv("S3V2_CONFORMANCE_SECRET_KEY"), "")
+	cfg := &aws.Config{
+		Credentials: creds,
+		Endpoint:    aws.String(endpoint),
+		Region:      aws.String(region),
+	}
+	if pathStyle {
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	var opts []s3v2.Option
+	if name := os.Getenv("S3V2_CONFORMANCE_PROFILE"); name != "" {
+		profile, ok := profiles[name]
+		if !ok {
+			t.Fatalf("unknown profile %q", name)
+		}
+		opts = append(opts, profile.Option())
+	}
+
+	svc := awsv1.NewS3Client(cfg, opts...)
+	results := Run(&Env{
+		Client:    svc,
+		Bucket:    bucket,
+		PathStyle: aws.BoolValue(svc.Config.S3ForcePathStyle),
+		Options:   opts,
+	})
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Case, r.Err)
+		} else {
+			t.Logf("%s: ok", r.Case)
+		}
+	}
+}
+
+func TestRunFakeServer(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := s3v2test.NewServer()
+	defer srv.Close()
+	srv.Handle("HEAD", "/bucket", s3v2test.Response{})
+	srv.Handle("GET", "/", s3v2test.Response{
+		Body: []byte(`<ListAllMyBucketsResult><Buckets></Buckets></ListAllMyBucketsResult>`),
+	})
+
+	results := Run(&Env{
+		Client:    awsv1.NewS3Client(srv.Config()),
+		Bucket:    "bucket",
+		PathStyle: true,
+	})
+	assert.Len(results, len(Cases()))
+	assert.Equal("ListBuckets", results[0].Case)
+	assert.NoError(results[0].Err)
+}
+
+func TestReport(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	failed := Report(&out, []Result{
+		{Case: "a"},
+		{Case: "b", Err: errors.New("SignatureDoesNotMatch")},
+	})
+	assert.Equal(1, failed)
+	assert.Equal("ok   a\nFAIL b: SignatureDoesNotMatch\n", out.String())
+}
@@ -0,0 +1,24 @@
+package s3v2
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// CopySource returns the value of the x-amz-copy-source header of a copy
+// of the key in bucket, "/bucket/key" with the key escaped the way S3
+// decodes it, and the version appended as "?versionId=" if it is set
+func CopySource(bucket, key, versionID string) string {
+	source := "/" + bucket + "/" + escapeUnreserved(key, true)
+	if versionID != "" {
+		source += "?versionId=" + url.QueryEscape(versionID)
+	}
+	return source
+}
+
+// SetCopySource sets the x-amz-copy-source header of a CopyObject or
+// UploadPartCopy request, before it is signed, so the escaped source is
+// signed as a canonical amz header exactly as it is sent
+func SetCopySource(req *http.Request, bucket, key, versionID string) {
+	req.Header.Set("X-Amz-Copy-Source", CopySource(bucket, key, versionID))
+}
@@ -0,0 +1,38 @@
+package s3v2
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopySource(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		key, versionID, expected string
+	}{
+		{"photos/puppy.jpg", "", "/johnsmith/photos/puppy.jpg"},
+		{"photos/my puppy+kitten.jpg", "", "/johnsmith/photos/my%20puppy%2Bkitten.jpg"},
+		{"a?b#c&d=e", "", "/johnsmith/a%3Fb%23c%26d%3De"},
+		{"Übergröße/犬.jpg", "", "/johnsmith/%C3%9Cbergr%C3%B6%C3%9Fe/%E7%8A%AC.jpg"},
+		{"photos/puppy.jpg", "3HL4kqtJlcpXroDTDmJ+rmSpXd3dIbrHY", "/johnsmith/photos/puppy.jpg?versionId=3HL4kqtJlcpXroDTDmJ%2BrmSpXd3dIbrHY"},
+	}
+	for _, test := range tests {
+		assert.Equal(test.expected, CopySource("johnsmith", test.key, test.versionID), test.key)
+	}
+}
+
+func TestSetCopySource(t *testing.T) {
+	assert := assert.New(t)
+
+	r := httptest.NewRequest("PUT", "https://johnsmith.s3.amazonaws.com/photos/copy%20of%20puppy.jpg", nil)
+	SetCopySource(r, "johnsmith", "photos/my puppy.jpg", "3HL4kqtJlcpXroDTDmJ+rmSpXd3dIbrHY")
+	signTestRequest(r)
+
+	assert.Equal("x-amz-copy-source:/johnsmith/photos/my%20puppy.jpg?versionId=3HL4kqtJlcpXroDTDmJ%2BrmSpXd3dIbrHY\n",
+		CanonicalAmzHeaders(r.Header))
+	_, err := NewVerifier(testSecretLookup).Verify(r)
+	assert.NoError(err)
+}
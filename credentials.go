@@ -0,0 +1,110 @@
+package s3v2
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CredentialsProvider provides the keys requests are signed with. The
+// awsv1 and awsv2 packages adapt the credentials of the SDKs. Retrieve is
+// called for every request signed, so providers that fetch keys should
+// cache them.
+type CredentialsProvider interface {
+	// Retrieve returns the keys to sign with. An empty access key id
+	// means the credentials are anonymous.
+	Retrieve(ctx context.Context) (accessKeyID, secretAccessKey, sessionToken string, err error)
+}
+
+// ExpiringCredentialsProvider is a CredentialsProvider of temporary
+// credentials, which are refreshed before signing when they expire within
+// the window of WithCredentialRefreshWindow
+type ExpiringCredentialsProvider interface {
+	CredentialsProvider
+	// ExpiresAt returns when the credentials last retrieved expire, or
+	// false if they don't expire
+	ExpiresAt() (time.Time, bool)
+	// Expire makes the next Retrieve fetch the credentials again
+	Expire()
+}
+
+// StaticCredentials is a CredentialsProvider of fixed keys
+type StaticCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Retrieve implements CredentialsProvider
+func (c StaticCredentials) Retrieve(ctx context.Context) (string, string, string, error) {
+	return c.AccessKeyID, c.SecretAccessKey, c.SessionToken, nil
+}
+
+// refreshedExpiries are the expiries of the credentials refreshed early by
+// the signers of a refresh window. Credentials that expire within the
+// window as soon as they are fetched would otherwise be fetched again for
+// every request.
+type refreshedExpiries struct {
+	mu       sync.Mutex
+	expiries map[int64]bool
+}
+
+// add records that credentials expiring at expiresAt were refreshed early,
+// and forgets those that expired before now
+func (r *refreshedExpiries) add(expiresAt, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.expiries == nil {
+		r.expiries = make(map[int64]bool)
+	}
+	for expiry := range r.expiries {
+		if expiry < now.UnixNano() {
+			delete(r.expiries, expiry)
+		}
+	}
+	r.expiries[expiresAt.UnixNano()] = true
+}
+
+// contains reports whether credentials expiring at expiresAt were
+// refreshed early
+func (r *refreshedExpiries) contains(expiresAt time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.expiries[expiresAt.UnixNano()]
+}
+
+// credentialsValue are the keys retrieved from a CredentialsProvider
+type credentialsValue struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// retrieveCredentials retrieves the keys of provider, giving up when ctx is
+// done even if the provider does not, such as one blocked on a hung
+// instance metadata service
+func retrieveCredentials(ctx context.Context, provider CredentialsProvider) (credentialsValue, error) {
+	retrieve := func() (value credentialsValue, err error) {
+		value.AccessKeyID, value.SecretAccessKey, value.SessionToken, err = provider.Retrieve(ctx)
+		return value, err
+	}
+	if _, static := provider.(StaticCredentials); static || ctx.Done() == nil {
+		return retrieve()
+	}
+
+	type result struct {
+		value credentialsValue
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := retrieve()
+		done <- result{value, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return credentialsValue{}, ctx.Err()
+	}
+}
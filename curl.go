@@ -0,0 +1,78 @@
+package s3v2
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// CurlCommand returns a curl command line that sends the signed request,
+// with every argument quoted for a POSIX shell, to reproduce requests
+// outside of Go. A request body must be rereadable with GetBody and is
+// passed inline, so it can't contain NUL bytes.
+func CurlCommand(req *http.Request) (string, error) {
+	args := []string{"curl"}
+	switch req.Method {
+	case "", http.MethodGet:
+	case http.MethodHead:
+		args = append(args, "--head")
+	default:
+		args = append(args, "-X", shellQuote(req.Method))
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if req.Host != "" && req.Host != req.URL.Host {
+		args = append(args, "-H", shellQuote("Host: "+req.Host))
+	}
+	for _, name := range names {
+		// curl sets the length of the body it sends itself
+		if http.CanonicalHeaderKey(name) == "Content-Length" {
+			continue
+		}
+		for _, value := range req.Header[name] {
+			args = append(args, "-H", shellQuote(name+": "+value))
+		}
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := rereadBody(req)
+		if err != nil {
+			return "", err
+		}
+		if bytes.IndexByte(body, 0) >= 0 {
+			return "", fmt.Errorf("request body contains NUL bytes")
+		}
+		args = append(args, "--data-binary", shellQuote(string(body)))
+	}
+
+	args = append(args, shellQuote(req.URL.String()))
+	return strings.Join(args, " "), nil
+}
+
+// rereadBody returns the body of the request without consuming it
+func rereadBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body can't be reread, GetBody is not set")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@%+,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
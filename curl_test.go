@@ -0,0 +1,43 @@
+package s3v2
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurlCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	req, _ := http.NewRequest("PUT", "http://localhost:9000/bucket/it's?acl", strings.NewReader("it's here"))
+	req.Header.Set("Date", "Tue, 27 Mar 2007 21:06:08 +0000")
+	req.Header.Set("Authorization", "AWS AKID:sig=")
+	req.Header.Set("Content-Length", "9")
+
+	cmd, err := CurlCommand(req)
+	assert.NoError(err)
+	assert.Equal(`curl -X PUT -H 'Authorization: AWS AKID:sig=' -H 'Date: Tue, 27 Mar 2007 21:06:08 +0000' `+
+		`--data-binary 'it'\''s here' 'http://localhost:9000/bucket/it'\''s?acl'`, cmd)
+
+	req, _ = http.NewRequest("HEAD", "http://localhost:9000/bucket/key", nil)
+	req.Host = "bucket.s3.local"
+	cmd, err = CurlCommand(req)
+	assert.NoError(err)
+	assert.Equal(`curl --head -H 'Host: bucket.s3.local' http://localhost:9000/bucket/key`, cmd)
+
+	req, _ = http.NewRequest("PUT", "http://localhost:9000/bucket/key", strings.NewReader("a\x00b"))
+	_, err = CurlCommand(req)
+	assert.Error(err)
+}
+
+func TestShellQuote(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("abc", shellQuote("abc"))
+	assert.Equal("''", shellQuote(""))
+	assert.Equal("'a b'", shellQuote("a b"))
+	assert.Equal(`'$HOME'`, shellQuote("$HOME"))
+	assert.Equal(`'a'\''b'`, shellQuote("a'b"))
+}
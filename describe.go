@@ -0,0 +1,136 @@
+package s3v2
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Description is what a presigned URL grants, decoded without the secret
+// key
+type Description struct {
+	AccessKeyID string
+	Expires     time.Time
+	Signature   string
+	// Temporary is set when the URL was presigned with temporary
+	// credentials, and so stops working when their session token expires
+	Temporary bool
+
+	Method string
+	Host   string
+	Bucket string
+	Key    string
+	// PathStyle is set when the bucket is the first segment of the path
+	// rather than part of the host
+	PathStyle bool
+	// CanonicalResource is the resource the URL was signed for
+	CanonicalResource string
+	// SubResources are the signed query parameters other than the
+	// response header overrides, such as versionId
+	SubResources url.Values
+	// Overrides are the response headers the URL overrides, by header
+	// name, such as Content-Disposition
+	Overrides map[string]string
+}
+
+// DescribePresignedURL decodes who presigned the URL, when it expires, the
+// bucket and key it is for, and the response headers it overrides, for
+// inspecting presigned links that don't work. The bucket is found in the
+// host of virtual host style URLs to S3 and in the path of the others.
+func DescribePresignedURL(rawURL string) (Description, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Description{}, err
+	}
+	if u.Host == "" {
+		return Description{}, &URLError{URL: rawURL, Err: ErrMissingHost}
+	}
+	params, err := ParsePresignedQuery(u.Query())
+	if err != nil {
+		return Description{}, err
+	}
+
+	d := Description{
+		AccessKeyID:  params.AccessKeyID,
+		Expires:      params.Expires,
+		Signature:    params.Signature,
+		Temporary:    params.SecurityToken != "",
+		Method:       http.MethodGet,
+		Host:         u.Host,
+		SubResources: make(url.Values),
+		Overrides:    make(map[string]string),
+	}
+
+	d.Bucket, d.PathStyle = bucketFromS3Host(u.Host)
+	path := strings.TrimPrefix(u.Path, "/")
+	if d.PathStyle {
+		d.Bucket, path, _ = strings.Cut(path, "/")
+	}
+	d.Key = path
+
+	signed := &url.URL{Path: u.Path, RawPath: u.RawPath,
+		RawQuery: stripQueryParams(u.RawQuery, presignQueryParams)}
+	d.CanonicalResource = canonicalResource(signed, requestPath(signed), u.Host, d.Bucket, d.PathStyle, nil)
+
+	for name, values := range u.Query() {
+		switch {
+		case presignQueryParams[name]:
+		case strings.HasPrefix(name, "response-"):
+			d.Overrides[http.CanonicalHeaderKey(strings.TrimPrefix(name, "response-"))] = values[0]
+		case subResources[name]:
+			d.SubResources[name] = values
+		}
+	}
+	return d, nil
+}
+
+// bucketFromS3Host returns the bucket of a virtual host style host of S3,
+// such as "johnsmith.s3.us-west-2.amazonaws.com", or reports the bucket is
+// in the path for other hosts
+func bucketFromS3Host(host string) (bucket string, pathStyle bool) {
+	name := strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(name); err == nil {
+		name = h
+	}
+	if !strings.HasSuffix(name, ".amazonaws.com") {
+		return "", true
+	}
+	for _, marker := range []string{".s3.", ".s3-"} {
+		if i := strings.Index(name, marker); i > 0 {
+			return bucketFromHost(host, name[i+1:])
+		}
+	}
+	return "", true
+}
+
+// Expired reports whether the URL has expired at now
+func (d Description) Expired(now time.Time) bool {
+	return now.Unix() > d.Expires.Unix()
+}
+
+// String describes the URL on several lines, for people
+func (d Description) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "access key:  %s\n", d.AccessKeyID)
+	if d.Temporary {
+		b.WriteString("credentials: temporary\n")
+	}
+	fmt.Fprintf(&b, "expires:     %s\n", d.Expires.UTC().Format(time.RFC1123))
+	fmt.Fprintf(&b, "bucket:      %s\n", d.Bucket)
+	fmt.Fprintf(&b, "key:         %s\n", d.Key)
+	fmt.Fprintf(&b, "resource:    %s\n", d.CanonicalResource)
+
+	names := make([]string, 0, len(d.Overrides))
+	for name := range d.Overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "override:    %s: %s\n", name, d.Overrides[name])
+	}
+	return b.String()
+}
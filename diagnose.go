@@ -0,0 +1,110 @@
+package s3v2
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Report describes the first difference between an expected and an actual
+// string to sign
+type Report struct {
+	// Equal is true when the strings to sign are identical
+	Equal bool
+	// Line is the 1-based line of the first difference
+	Line int
+	// Field names the part of the string to sign on Line
+	Field string
+	// Offset is the byte offset of the first difference in the strings
+	Offset int
+	// Column is the byte offset of the first difference in Line
+	Column int
+
+	ExpectedLine string
+	ActualLine   string
+}
+
+func (r Report) String() string {
+	if r.Equal {
+		return "strings to sign are equal"
+	}
+	return fmt.Sprintf("strings to sign differ at line %d (%s), byte %d:\nexpected: %q\nactual:   %q",
+		r.Line, r.Field, r.Offset, r.ExpectedLine, r.ActualLine)
+}
+
+// Diff pinpoints the first difference between the expected string to sign,
+// such as the one reported by a server, and the actual string to sign
+func Diff(expected, actual string) Report {
+	if expected == actual {
+		return Report{Equal: true}
+	}
+
+	offset := 0
+	for offset < len(expected) && offset < len(actual) && expected[offset] == actual[offset] {
+		offset++
+	}
+
+	lineStart := strings.LastIndex(expected[:offset], "\n") + 1
+	line := strings.Count(expected[:offset], "\n") + 1
+
+	return Report{
+		Line:         line,
+		Field:        stringToSignField(line, strings.Count(expected, "\n")+1),
+		Offset:       offset,
+		Column:       offset - lineStart,
+		ExpectedLine: lineAt(expected, lineStart),
+		ActualLine:   lineAt(actual, lineStart),
+	}
+}
+
+// lineAt returns the line of s that starts at start
+func lineAt(s string, start int) string {
+	if start > len(s) {
+		return ""
+	}
+	s = s[start:]
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// stringToSignField names the field on a 1-based line of a string to sign
+// that has lines lines in total
+func stringToSignField(line, lines int) string {
+	switch {
+	case line == 1:
+		return "HTTP-Verb"
+	case line == 2:
+		return "Content-MD5"
+	case line == 3:
+		return "Content-Type"
+	case line == 4:
+		return "Date"
+	case line == lines:
+		return "CanonicalizedResource"
+	default:
+		return "CanonicalizedAmzHeaders"
+	}
+}
+
+// ParseErrorDocument parses the XML body of an S3 error response. For
+// SignatureDoesNotMatch errors StringToSign is decoded from
+// StringToSignBytes when present, since it preserves the exact bytes the
+// server signed.
+func ParseErrorDocument(body []byte) (ErrorDocument, error) {
+	var doc ErrorDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return ErrorDocument{}, err
+	}
+
+	if doc.StringToSignBytes != "" {
+		b, err := hex.DecodeString(strings.Join(strings.Fields(doc.StringToSignBytes), ""))
+		if err != nil {
+			return ErrorDocument{}, fmt.Errorf("invalid StringToSignBytes: %v", err)
+		}
+		doc.StringToSign = string(b)
+	}
+	return doc, nil
+}
@@ -0,0 +1,4 @@
+// Package difftest cross-checks the signatures of s3v2 against other V2
+// implementations over a matrix of requests. It only has tests, in their
+// own package so the other implementations are test dependencies only.
+package difftest
@@ -0,0 +1,166 @@
+package s3v2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// V4Validator authenticates a request signed with SigV4, returning an
+// error, such as a *VerifyError, if it is not authenticated
+type V4Validator func(r *http.Request) error
+
+// Downgrader accepts requests from clients that only sign with SigV4 and
+// forwards them to a legacy backend, such as an old Ceph or Riak CS, that
+// only accepts the V2 signature. Its Director and Transport are used
+// together as the Director and Transport of an httputil.ReverseProxy:
+//
+//	proxy := &httputil.ReverseProxy{
+//		Director:  d.Director,
+//		Transport: d.Transport(nil),
+//	}
+//
+// A Downgrader is safe for concurrent use.
+type Downgrader struct {
+	validate V4Validator
+	backend  *url.URL
+	signer   *Signer
+}
+
+// NewDowngrader returns a Downgrader that authenticates requests with
+// validate and forwards them to the scheme and host of backend, signed
+// with the keys of provider configured by opts. Requests are forwarded path style, under
+// the path of backend if it has one.
+func NewDowngrader(validate V4Validator, backend *url.URL, provider CredentialsProvider, opts ...Option) *Downgrader {
+	opts = append(append([]Option(nil), opts...), WithPathStyle(true))
+	return &Downgrader{
+		validate: validate,
+		backend:  backend,
+		signer:   NewSigner(provider, opts...),
+	}
+}
+
+// v4Headers are the headers of SigV4 authentication
+var v4Headers = []string{"Authorization", "X-Amz-Date", "X-Amz-Content-Sha256", "X-Amz-Security-Token"}
+
+// v4QueryParams are the query parameters of presigned SigV4 requests
+var v4QueryParams = map[string]bool{
+	"X-Amz-Algorithm":      true,
+	"X-Amz-Credential":     true,
+	"X-Amz-Date":           true,
+	"X-Amz-Expires":        true,
+	"X-Amz-SignedHeaders":  true,
+	"X-Amz-Signature":      true,
+	"X-Amz-Security-Token": true,
+}
+
+type downgradeErrorKey struct{}
+
+// downgradeError is the reason a request is not forwarded, and the path
+// it was sent to
+type downgradeError struct {
+	err  error
+	path string
+}
+
+// Director validates the outbound request of the proxy, which still has
+// the SigV4 authentication of the client, removes the authentication and
+// points the request at the backend. Requests that fail validation are
+// answered by the Transport with an S3 style XML error instead of being
+// forwarded.
+func (d *Downgrader) Director(out *http.Request) {
+	err := d.validate(out)
+	if err == nil && strings.HasPrefix(out.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		// the chunk signatures of the body can't be checked or
+		// removed
+		err = &VerifyError{
+			Code:       "NotImplemented",
+			Message:    "Chunked payload signing is not supported.",
+			StatusCode: http.StatusNotImplemented,
+		}
+	}
+	if err != nil {
+		ctx := context.WithValue(out.Context(), downgradeErrorKey{},
+			downgradeError{err: err, path: out.URL.Path})
+		*out = *out.WithContext(ctx)
+		return
+	}
+
+	for _, name := range v4Headers {
+		out.Header.Del(name)
+	}
+	u, err := forwardURL(d.backend, out, false, "", stripQueryParams(out.URL.RawQuery, v4QueryParams))
+	if err != nil {
+		ctx := context.WithValue(out.Context(), downgradeErrorKey{},
+			downgradeError{err: errAccessDenied(err.Error()), path: out.URL.Path})
+		*out = *out.WithContext(ctx)
+		return
+	}
+	out.URL = u
+	out.Host = ""
+}
+
+// Transport returns a RoundTripper that signs requests prepared by the
+// Director with the V2 signature and sends them with next, or
+// http.DefaultTransport if it is nil
+func (d *Downgrader) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return downgradeTransport{signer: d.signer, next: next}
+}
+
+type downgradeTransport struct {
+	signer *Signer
+	next   http.RoundTripper
+}
+
+func (t downgradeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if derr, ok := req.Context().Value(downgradeErrorKey{}).(downgradeError); ok {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return errorResponse(req, derr.err, derr.path)
+	}
+
+	// a RoundTripper must not modify the request
+	signed := req.Clone(req.Context())
+	if _, err := t.signer.Sign(signed); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(signed)
+}
+
+// errorResponse returns the S3 error response to the request for the
+// verification error of resource
+func errorResponse(req *http.Request, err error, resource string) (*http.Response, error) {
+	body, err2 := NewErrorDocument(err, resource).Marshal()
+	if err2 != nil {
+		return nil, err2
+	}
+
+	status := http.StatusForbidden
+	var verr *VerifyError
+	if errors.As(err, &verr) && verr.StatusCode != 0 {
+		status = verr.StatusCode
+	}
+	return &http.Response{
+		Status:     strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type":   {"application/xml"},
+			"Content-Length": {strconv.Itoa(len(body))},
+		},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,215 @@
+package s3v2
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// bucketPlaceholder marks where the bucket is in the host of an endpoint
+// template
+const bucketPlaceholder = "{bucket}"
+
+// BasePathMode is whether the path an S3 API is mounted under is part of
+// the canonical resource, which depends on the convention of the server
+type BasePathMode int
+
+const (
+	// BasePathSigned signs the base path as part of the canonical
+	// resource, before the bucket
+	BasePathSigned BasePathMode = iota
+	// BasePathUnsigned leaves the base path out of the canonical
+	// resource, for servers that verify the path the API sees
+	BasePathUnsigned
+)
+
+// WithBasePath signs requests to an S3 API mounted under basePath, such as
+// "/s3" behind an API gateway, with the base path signed or not as mode
+// says. The bucket of virtual host style requests is canonicalized after
+// the base path. Requests whose path is not under the base path are signed
+// as-is.
+func WithBasePath(basePath string, mode BasePathMode) Option {
+	basePath = strings.TrimSuffix("/"+strings.TrimPrefix(basePath, "/"), "/")
+	return func(v2 *signer) {
+		v2.BasePath = basePath
+		v2.BasePathMode = mode
+	}
+}
+
+// cutBasePath returns the escaped path without the base path, and whether
+// the path was under it
+func cutBasePath(path, basePath string) (string, bool) {
+	rest, found := strings.CutPrefix(path, basePath)
+	if !found || rest != "" && rest[0] != '/' {
+		return path, false
+	}
+	if rest == "" {
+		rest = "/"
+	}
+	return rest, true
+}
+
+// BucketInResource is whether the bucket of a request is prefixed to its
+// canonical resource. Some old appliances serve the REST API under
+// alternate hostnames and sign the resource without the bucket.
+type BucketInResource int
+
+const (
+	// BucketInResourceAuto prefixes the bucket of virtual host style
+	// requests, found from the bucket and host of the request
+	BucketInResourceAuto BucketInResource = iota
+	// BucketInResourceAlways prefixes the known bucket of requests that
+	// are not path style, whatever their host
+	BucketInResourceAlways
+	// BucketInResourceNever signs the path of the request as the
+	// canonical resource, without a bucket prefix
+	BucketInResourceNever
+)
+
+// String returns "auto", "always" or "never"
+func (b BucketInResource) String() string {
+	switch b {
+	case BucketInResourceAuto:
+		return "auto"
+	case BucketInResourceAlways:
+		return "always"
+	case BucketInResourceNever:
+		return "never"
+	}
+	return fmt.Sprintf("BucketInResource(%d)", int(b))
+}
+
+// ParseBucketInResource parses "auto", "always" or "never"
+func ParseBucketInResource(s string) (BucketInResource, error) {
+	switch strings.ToLower(s) {
+	case "auto", "":
+		return BucketInResourceAuto, nil
+	case "always":
+		return BucketInResourceAlways, nil
+	case "never":
+		return BucketInResourceNever, nil
+	}
+	return 0, fmt.Errorf("s3v2: bucket in resource policy %q is not auto, always or never", s)
+}
+
+// WithBucketInResource signs requests with the bucket prefixed to the
+// canonical resource as policy says. Endpoint templates with their own
+// policy take precedence for requests to their endpoint.
+func WithBucketInResource(policy BucketInResource) Option {
+	return func(v2 *signer) {
+		v2.BucketInResource = policy
+	}
+}
+
+// WithVerifierBucketInResource verifies requests signed with
+// WithBucketInResource
+func WithVerifierBucketInResource(policy BucketInResource) VerifierOption {
+	return func(v *Verifier) {
+		v.bucketInResource = policy
+	}
+}
+
+// EndpointTemplate describes an endpoint whose S3 API is mounted under a
+// base path, such as the Walrus service of Eucalyptus or other legacy
+// appliances, so the bucket and path of requests are canonicalized
+// correctly. The canonical resource is the base path, unless it is
+// unsigned, the bucket, and the rest of the path, whether the bucket was in
+// the host or the path.
+type EndpointTemplate struct {
+	// Host is the host of the endpoint, with a port if it has one, and
+	// "{bucket}" where virtual host style requests have the bucket
+	Host string
+	// BasePath is the path the S3 API is mounted under, such as
+	// "/services/Walrus", or "" if it is not mounted under one
+	BasePath string
+	// BasePathMode is whether the base path is part of the canonical
+	// resource, which it is by default
+	BasePathMode BasePathMode
+	// BucketInResource is whether the bucket of requests to the endpoint
+	// is part of the canonical resource, which it is when auto
+	BucketInResource BucketInResource
+}
+
+// ParseEndpointTemplate parses an endpoint template of the form
+// "{bucket}.objects.internal:8773/services/Walrus", with an optional
+// scheme, into its host and base path. The "{bucket}" placeholder is
+// optional, and may only appear once in the host.
+func ParseEndpointTemplate(template string) (*EndpointTemplate, error) {
+	rest := template
+	if _, after, found := strings.Cut(rest, "://"); found {
+		rest = after
+	}
+	host, path, _ := strings.Cut(rest, "/")
+	if host == "" {
+		return nil, &URLError{URL: template, Err: ErrMissingHost}
+	}
+	if strings.Count(host, bucketPlaceholder) > 1 {
+		return nil, fmt.Errorf("s3v2: endpoint template %q has more than one %s", template, bucketPlaceholder)
+	}
+	if strings.Contains(path, bucketPlaceholder) {
+		return nil, fmt.Errorf("s3v2: endpoint template %q has %s in its path", template, bucketPlaceholder)
+	}
+
+	basePath := strings.TrimSuffix("/"+path, "/")
+	if _, err := url.PathUnescape(basePath); err != nil {
+		return nil, fmt.Errorf("s3v2: endpoint template %q: %w", template, err)
+	}
+	return &EndpointTemplate{Host: strings.ToLower(host), BasePath: basePath}, nil
+}
+
+// WithEndpointTemplate signs requests to the endpoint of template with
+// their bucket and base path canonicalized as the template describes.
+// Requests to other hosts are signed as if there was no template.
+func WithEndpointTemplate(template *EndpointTemplate) Option {
+	return func(v2 *signer) {
+		v2.EndpointTemplate = template
+	}
+}
+
+// bucket returns the bucket of a request to host, "" if the request is
+// path style, or false if host is not the host of the template
+func (t *EndpointTemplate) bucket(host string) (string, bool) {
+	host = strings.ToLower(host)
+	pattern := t.Host
+	if _, _, err := net.SplitHostPort(pattern); err != nil {
+		// the template has no port, so any port matches
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+
+	prefix, suffix, virtual := strings.Cut(pattern, bucketPlaceholder)
+	if !virtual {
+		return "", host == pattern
+	}
+	if host == strings.TrimPrefix(suffix, ".") {
+		// requests to the service host itself are path style
+		return "", true
+	}
+	if len(host) <= len(prefix)+len(suffix) ||
+		!strings.HasPrefix(host, prefix) || !strings.HasSuffix(host, suffix) {
+		return "", false
+	}
+	return host[len(prefix) : len(host)-len(suffix)], true
+}
+
+// canonicalPath returns the canonical resource, without subresources, of
+// a request to host for the escaped path, or false if host is not the host
+// of the template. The bucket is left out when policy is never.
+func (t *EndpointTemplate) canonicalPath(host, path string, policy BucketInResource) (string, bool) {
+	bucket, ok := t.bucket(host)
+	if !ok {
+		return "", false
+	}
+
+	rest, _ := cutBasePath(path, t.BasePath)
+	basePath := t.BasePath
+	if t.BasePathMode == BasePathUnsigned {
+		basePath = ""
+	}
+	if bucket == "" || policy == BucketInResourceNever {
+		return basePath + rest, true
+	}
+	return basePath + "/" + bucket + rest, true
+}
@@ -0,0 +1,161 @@
+package s3v2
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEndpointTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	template, err := ParseEndpointTemplate("http://{bucket}.Objects.internal:8773/services/Walrus/")
+	assert.NoError(err)
+	assert.Equal(&EndpointTemplate{Host: "{bucket}.objects.internal:8773", BasePath: "/services/Walrus"}, template)
+
+	template, err = ParseEndpointTemplate("objects.internal")
+	assert.NoError(err)
+	assert.Equal(&EndpointTemplate{Host: "objects.internal"}, template)
+
+	for _, bad := range []string{"", "/services/Walrus", "{bucket}.{bucket}.internal", "objects.internal/{bucket}", "objects.internal/%zz"} {
+		_, err := ParseEndpointTemplate(bad)
+		assert.Error(err, bad)
+	}
+}
+
+func TestSignEndpointTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	template, err := ParseEndpointTemplate("{bucket}.objects.internal:8773/services/Walrus")
+	assert.NoError(err)
+	signer := newTestSigner(WithEndpointTemplate(template))
+
+	tests := []struct {
+		url      string
+		resource string
+	}{
+		{"http://johnsmith.objects.internal:8773/services/Walrus/photos/puppy.jpg?acl", "/services/Walrus/johnsmith/photos/puppy.jpg?acl"},
+		{"http://objects.internal:8773/services/Walrus/johnsmith/photos/puppy.jpg", "/services/Walrus/johnsmith/photos/puppy.jpg"},
+		{"http://john.smith.objects.internal:8773/services/Walrus", "/services/Walrus/john.smith/"},
+		{"http://objects.internal:8773/services/Walrus/", "/services/Walrus/"},
+		// the base path is added when the request was sent without it
+		{"http://johnsmith.objects.internal:8773/photos/puppy.jpg", "/services/Walrus/johnsmith/photos/puppy.jpg"},
+		{"http://johnsmith.objects.internal:8773/services/Walrusx", "/services/Walrus/johnsmith/services/Walrusx"},
+		// other hosts are signed without the template
+		{"http://johnsmith.objects.internal:9000/photos/puppy.jpg", "/photos/puppy.jpg"},
+		{"https://johnsmith.s3.amazonaws.com/photos/puppy.jpg", "/johnsmith/photos/puppy.jpg"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", test.url, nil)
+		result, err := signer.Build(req)
+		assert.NoError(err, test.url)
+		assert.Equal(test.resource, result.CanonicalResource, test.url)
+	}
+}
+
+func TestEndpointTemplateAnyPort(t *testing.T) {
+	assert := assert.New(t)
+
+	template := &EndpointTemplate{Host: "{bucket}.objects.internal"}
+	bucket, ok := template.bucket("johnsmith.objects.internal:8773")
+	assert.True(ok)
+	assert.Equal("johnsmith", bucket)
+
+	_, ok = template.bucket(".objects.internal")
+	assert.False(ok)
+}
+
+func TestSignBasePath(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		url      string
+		mode     BasePathMode
+		resource string
+	}{
+		{"https://gw.example.com/s3/johnsmith/photos/puppy.jpg?acl", BasePathSigned, "/s3/johnsmith/photos/puppy.jpg?acl"},
+		{"https://gw.example.com/s3/johnsmith/photos/puppy.jpg?acl", BasePathUnsigned, "/johnsmith/photos/puppy.jpg?acl"},
+		{"https://johnsmith.gw.example.com/s3/photos/puppy.jpg", BasePathSigned, "/s3/johnsmith/photos/puppy.jpg"},
+		{"https://johnsmith.gw.example.com/s3/photos/puppy.jpg", BasePathUnsigned, "/johnsmith/photos/puppy.jpg"},
+		{"https://gw.example.com/s3", BasePathUnsigned, "/"},
+		// paths outside the base path are signed as-is
+		{"https://gw.example.com/s3x/johnsmith", BasePathUnsigned, "/s3x/johnsmith"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", test.url, nil)
+		result, err := newTestSigner(WithBasePath("s3/", test.mode)).Build(req)
+		assert.NoError(err, test.url)
+		assert.Equal(test.resource, result.CanonicalResource, test.url)
+	}
+
+	template, _ := ParseEndpointTemplate("{bucket}.objects.internal:8773/services/Walrus")
+	template.BasePathMode = BasePathUnsigned
+	req, _ := http.NewRequest("GET", "http://johnsmith.objects.internal:8773/services/Walrus/photos/puppy.jpg", nil)
+	result, err := newTestSigner(WithEndpointTemplate(template)).Build(req)
+	assert.NoError(err)
+	assert.Equal("/johnsmith/photos/puppy.jpg", result.CanonicalResource)
+}
+
+func TestSignBucketInResource(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		url      string
+		opts     []Option
+		policy   BucketInResource
+		resource string
+	}{
+		{"https://johnsmith.s3.amazonaws.com/photos/puppy.jpg?acl", nil, BucketInResourceAuto, "/johnsmith/photos/puppy.jpg?acl"},
+		{"https://johnsmith.s3.amazonaws.com/photos/puppy.jpg?acl", nil, BucketInResourceNever, "/photos/puppy.jpg?acl"},
+		// an alternate hostname of the bucket is only prefixed when always
+		{"https://files.example.com/photos/puppy.jpg", []Option{WithBucket("johnsmith")}, BucketInResourceAuto, "/photos/puppy.jpg"},
+		{"https://files.example.com/photos/puppy.jpg", []Option{WithBucket("johnsmith")}, BucketInResourceAlways, "/johnsmith/photos/puppy.jpg"},
+		// path style requests already have the bucket in the path
+		{"https://s3.amazonaws.com/johnsmith/photos/puppy.jpg", []Option{WithBucket("johnsmith"), WithPathStyle(true)},
+			BucketInResourceAlways, "/johnsmith/photos/puppy.jpg"},
+		{"http://10.0.0.5:9000/johnsmith/photos/puppy.jpg", []Option{WithBucket("johnsmith")}, BucketInResourceAlways, "/johnsmith/photos/puppy.jpg"},
+		// without a known bucket always is auto
+		{"https://files.example.com/photos/puppy.jpg", nil, BucketInResourceAlways, "/photos/puppy.jpg"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", test.url, nil)
+		result, err := newTestSigner(append(test.opts, WithBucketInResource(test.policy))...).Build(req)
+		assert.NoError(err, test.url)
+		assert.Equal(test.resource, result.CanonicalResource, test.url, test.policy)
+	}
+
+	// the policy of a template is per endpoint
+	template, _ := ParseEndpointTemplate("{bucket}.objects.internal:8773/services/Walrus")
+	template.BucketInResource = BucketInResourceNever
+	signer := newTestSigner(WithEndpointTemplate(template))
+	req, _ := http.NewRequest("GET", "http://johnsmith.objects.internal:8773/services/Walrus/photos/puppy.jpg", nil)
+	result, err := signer.Build(req)
+	assert.NoError(err)
+	assert.Equal("/services/Walrus/photos/puppy.jpg", result.CanonicalResource)
+	req, _ = http.NewRequest("GET", "https://johnsmith.s3.amazonaws.com/photos/puppy.jpg", nil)
+	result, err = signer.Build(req)
+	assert.NoError(err)
+	assert.Equal("/johnsmith/photos/puppy.jpg", result.CanonicalResource)
+
+	// servers verify with the same policy
+	req, _ = http.NewRequest("GET", "http://johnsmith.s3.appliance.local/photos/puppy.jpg", nil)
+	signed, err := newTestSigner(WithBucketInResource(BucketInResourceNever)).SignCopy(req)
+	assert.NoError(err)
+	_, err = NewVerifier(testSecretLookup, WithVerifierBucketInResource(BucketInResourceNever)).Verify(signed)
+	assert.NoError(err)
+	_, err = NewVerifier(testSecretLookup).Verify(signed)
+	assert.ErrorIs(err, ErrSignatureMismatch)
+}
+
+func TestParseBucketInResource(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, policy := range []BucketInResource{BucketInResourceAuto, BucketInResourceAlways, BucketInResourceNever} {
+		parsed, err := ParseBucketInResource(policy.String())
+		assert.NoError(err)
+		assert.Equal(policy, parsed)
+	}
+	_, err := ParseBucketInResource("sometimes")
+	assert.Error(err)
+}
@@ -0,0 +1,52 @@
+package s3v2
+
+import "errors"
+
+// Errors returned by signing and verification, wrapping the underlying
+// cause when there is one, for use with errors.Is
+var (
+	// ErrNoCredentials is returned when the credentials to sign with
+	// can't be retrieved
+	ErrNoCredentials = errors.New("s3v2: no credentials")
+	// ErrAnonymousCredentials is wrapped with ErrNoCredentials when the
+	// credentials are anonymous and WithAllowAnonymous is not set
+	ErrAnonymousCredentials = errors.New("s3v2: anonymous credentials")
+	// ErrMissingHost is returned when the request to sign has no host
+	ErrMissingHost = errors.New("s3v2: request has no host")
+	// ErrUnsupportedURL is returned when the URL of the request to sign
+	// has an opaque form that isn't a path
+	ErrUnsupportedURL = errors.New("s3v2: unsupported URL")
+	// ErrMissingMethod is returned by Validate when the request has no
+	// method, which would be signed as empty rather than GET
+	ErrMissingMethod = errors.New("s3v2: request has no method")
+	// ErrConflictingAuth is wrapped by the *ConflictingAuthError of
+	// signing, and of Validate, when the request already carries
+	// authentication V2 signing would not replace, such as a SigV4
+	// Authorization or presigned query parameters
+	ErrConflictingAuth = errors.New("s3v2: request has conflicting authentication")
+	// ErrContentMD5Unavailable is returned by signing, and by Validate,
+	// when the request needs a Content-MD5 that can't be computed
+	// without consuming the body
+	ErrContentMD5Unavailable = errors.New("s3v2: Content-MD5 can't be computed from the body")
+	// ErrInvalidExpiry is returned when presigning a request that
+	// expires at or before the current time
+	ErrInvalidExpiry = errors.New("s3v2: presign expiry is not in the future")
+	// ErrExpiryTooLong is returned when presigning a request that is
+	// valid for longer than the maximum presign expiry
+	ErrExpiryTooLong = errors.New("s3v2: presign expiry is too long")
+	// ErrMalformedPresign is wrapped by the *PresignQueryError of
+	// presigned query parameters that are missing or invalid
+	ErrMalformedPresign = errors.New("s3v2: malformed presigned query")
+	// ErrExpiredPresign is wrapped by the VerifyError of a presigned
+	// request that has expired
+	ErrExpiredPresign = errors.New("s3v2: presigned request has expired")
+	// ErrSignatureMismatch is wrapped by the VerifyError of a request
+	// whose signature does not match
+	ErrSignatureMismatch = errors.New("s3v2: signature does not match")
+	// ErrNotAuthorized is wrapped by the VerifyError of a verified
+	// request the Authorizer of the Verifier denies
+	ErrNotAuthorized = errors.New("s3v2: request is not authorized")
+	// ErrBodyIntegrity is wrapped by the *IntegrityError of a response
+	// body that doesn't match its ETag or Content-MD5
+	ErrBodyIntegrity = errors.New("s3v2: response body does not match its checksum")
+)
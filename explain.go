@@ -0,0 +1,96 @@
+package s3v2
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DetailLevel is how much a Verifier tells clients about why a request
+// failed verification
+type DetailLevel int
+
+const (
+	// DetailStandard reports failures the way S3 does, with the string
+	// to sign of signature mismatches
+	DetailStandard DetailLevel = iota
+	// DetailVerbose also explains signature mismatches, such as which
+	// header was not signed, when the signature matches a variant of the
+	// string to sign
+	DetailVerbose
+	// DetailMinimal reports every failure as a SignatureDoesNotMatch
+	// without details, so clients can't probe which access keys exist or
+	// which part of a request is wrong. The cause is still wrapped for
+	// logging on the server.
+	DetailMinimal
+)
+
+// WithDetailLevel sets how much verification errors tell clients,
+// DetailStandard if not set
+func WithDetailLevel(level DetailLevel) VerifierOption {
+	return func(v *Verifier) {
+		v.detail = level
+	}
+}
+
+// errSignatureDoesNotMatchMinimal returns the error of any failure err at
+// DetailMinimal, which wraps err
+func errSignatureDoesNotMatchMinimal(err error) *VerifyError {
+	verr := errSignatureDoesNotMatch("", "", "")
+	verr.Err = err
+	return verr
+}
+
+// explainMismatch explains why signature, made with cred, doesn't match the
+// string to sign of v2 by finding the variant of the string to sign that it
+// does match, or returns "" if it matches none
+func explainMismatch(v2 signer, cred Credential, signature, date string) string {
+	matches := func(stringToSign string) bool {
+		v2.stringToSign = stringToSign
+		_, ok := matchSecret(v2, cred, signature)
+		return ok
+	}
+	stringToSign := func(contentMD5, contentType, amzHeaders, resource string) string {
+		return StringToSign(v2.Request.Method, contentMD5, contentType, date, amzHeaders, resource)
+	}
+	contentMD5 := v2.Request.Header.Get("Content-Md5")
+	contentType := v2.Request.Header.Get("Content-Type")
+
+	// the client addressed the bucket the other way
+	other := v2
+	other.PathStyle = !v2.PathStyle
+	other.buildCanonicalizedResource()
+	if other.canonicalResource != v2.canonicalResource &&
+		matches(stringToSign(contentMD5, contentType, v2.canonicalAmzHeaders, other.canonicalResource)) {
+		return "The signature was computed with the canonical resource " + other.canonicalResource +
+			" instead of " + v2.canonicalResource + "."
+	}
+
+	// a header was added after the request was signed, such as by a
+	// proxy
+	for _, name := range []string{"Content-Md5", "Content-Type"} {
+		if v2.Request.Header.Get(name) == "" {
+			continue
+		}
+		md5, typ := contentMD5, contentType
+		if name == "Content-Md5" {
+			md5 = ""
+		} else {
+			typ = ""
+		}
+		if matches(stringToSign(md5, typ, v2.canonicalAmzHeaders, v2.canonicalResource)) {
+			return "The " + http.CanonicalHeaderKey(name) + " header was not signed."
+		}
+	}
+	lines := strings.SplitAfter(v2.canonicalAmzHeaders, "\n")
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		without := strings.Join(lines[:i], "") + strings.Join(lines[i+1:], "")
+		if matches(stringToSign(contentMD5, contentType, without, v2.canonicalResource)) {
+			name, _, _ := strings.Cut(line, ":")
+			return "The " + name + " header was not signed."
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package s3v2
+
+import (
+	"crypto/hmac"
+	"hash"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// hmacPool reuses the HMAC state of a single secret across requests,
+// instead of keying a new HMAC for every request. Signing with a different
+// secret or method, such as after the credentials rotate, replaces the
+// pool.
+type hmacPool struct {
+	current atomic.Pointer[hmacPoolEntry]
+}
+
+type hmacPoolEntry struct {
+	// method and hash tell the signature methods apart, since methods with
+	// the same name may use different hashes
+	method string
+	hash   uintptr
+	secret string
+	pool   sync.Pool
+}
+
+// WithHMACPool reuses the HMAC state between the requests signed with the
+// option, which saves rekeying the HMAC for every request when clients
+// sign many requests with the same credentials. It is only worth it for
+// high throughput clients. The pool holds the state of one secret at a
+// time, so alternating between secrets, such as signing for several
+// tenants through WithCredentialResolver, rekeys the HMAC on every switch
+// and saves nothing.
+func WithHMACPool() Option {
+	pool := &hmacPool{}
+	return func(v2 *signer) {
+		v2.hmacPool = pool
+	}
+}
+
+// sum returns the HMAC of data keyed by secret
+func (p *hmacPool) sum(method SignatureMethod, secret string, data string) []byte {
+	hashFn := reflect.ValueOf(method.Hash).Pointer()
+	entry := p.current.Load()
+	if entry == nil || entry.secret != secret || entry.method != method.Name || entry.hash != hashFn {
+		entry = &hmacPoolEntry{method: method.Name, hash: hashFn, secret: secret}
+		entry.pool.New = func() any {
+			return hmac.New(method.Hash, []byte(secret))
+		}
+		p.current.Store(entry)
+	}
+
+	h := entry.pool.Get().(hash.Hash)
+	h.Write([]byte(data))
+	sum := h.Sum(nil)
+	h.Reset()
+	entry.pool.Put(h)
+	return sum
+}
@@ -0,0 +1,95 @@
+package s3v2
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// SignInfo describes how a request was signed, for tracing
+type SignInfo struct {
+	// SignatureVersion is always "2"
+	SignatureVersion  string
+	PathStyle         bool
+	Presigned         bool
+	Bucket            string
+	CanonicalResource string
+}
+
+// SignHook is called with the context of the request, or of
+// SignWithContext, after a request is signed, such as to record the
+// signing on a trace span (see the s3v2otel package)
+type SignHook func(ctx context.Context, info SignInfo)
+
+// WithSignHook calls hook after each request is signed or presigned
+func WithSignHook(hook SignHook) Option {
+	return func(v2 *signer) {
+		v2.SignHooks = append(v2.SignHooks, hook)
+	}
+}
+
+// AuditRecord is the record of a signed request passed to the audit func
+type AuditRecord struct {
+	// Time is when the request was signed: the time in its signed Date
+	// (or x-amz-date) header, or when a presigned request was signed
+	Time              time.Time
+	AccessKeyID       string
+	Method            string
+	CanonicalResource string
+	Signature         string
+	Presigned         bool
+	// Expires is when a presigned request expires
+	Expires time.Time
+}
+
+// WithAuditFunc calls audit with the record of every request signed or
+// presigned, for an authoritative log of credentialed requests. Requests
+// that fail to be signed are not audited.
+func WithAuditFunc(audit func(AuditRecord)) Option {
+	return func(v2 *signer) {
+		v2.AuditFunc = audit
+	}
+}
+
+// signed calls the audit func and sign hooks for a request signed at
+// signedAt, and presigned until expires if it is set
+func (v2 *signer) signed(accessKeyID string, signedAt, expires time.Time) {
+	presigned := !expires.IsZero()
+	if v2.AuditFunc != nil {
+		v2.AuditFunc(AuditRecord{
+			Time:              signedAt,
+			AccessKeyID:       accessKeyID,
+			Method:            v2.Request.Method,
+			CanonicalResource: v2.canonicalResource,
+			Signature:         v2.signature,
+			Presigned:         presigned,
+			Expires:           expires,
+		})
+	}
+	if len(v2.SignHooks) == 0 {
+		return
+	}
+	info := SignInfo{
+		SignatureVersion:  "2",
+		PathStyle:         v2.PathStyle,
+		Presigned:         presigned,
+		Bucket:            v2.Bucket,
+		CanonicalResource: v2.canonicalResource,
+	}
+	if info.Bucket == "" {
+		info.Bucket = bucketFromResource(v2.canonicalResource)
+	}
+	for _, hook := range v2.SignHooks {
+		hook(v2.context(), info)
+	}
+}
+
+// bucketFromResource returns the bucket of a canonical resource, which
+// always starts with the bucket when there is one
+func bucketFromResource(resource string) string {
+	resource = strings.TrimPrefix(resource, "/")
+	if i := strings.IndexAny(resource, "/?"); i >= 0 {
+		resource = resource[:i]
+	}
+	return resource
+}
@@ -0,0 +1,104 @@
+package s3v2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// IntegrityError is returned by reading the end of a body checked by
+// VerifyBody when it doesn't match the checksum of the response. It wraps
+// ErrBodyIntegrity.
+type IntegrityError struct {
+	// Header is the header of the expected checksum, ETag or Content-MD5
+	Header   string
+	Expected string
+	Actual   string
+}
+
+func (e *IntegrityError) Error() string {
+	return ErrBodyIntegrity.Error() + ": " + e.Header + " is " + e.Expected + ", body has " + e.Actual
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return ErrBodyIntegrity
+}
+
+// VerifyBody replaces the body of the response to a GET of an object with
+// one that computes its MD5 as it is read, and returns an *IntegrityError
+// instead of io.EOF when the body doesn't match the Content-MD5 or ETag of
+// the response, to catch objects corrupted by the disks of the server. It
+// reports whether the body can be checked: partial content, multipart
+// objects and objects encrypted with SSE-KMS or SSE-C have ETags that
+// aren't the MD5 of the body, and those responses are left untouched.
+func VerifyBody(resp *http.Response) bool {
+	header, expected := expectedMD5(resp)
+	if expected == nil {
+		return false
+	}
+	resp.Body = &verifyingBody{
+		ReadCloser: resp.Body,
+		hash:       md5.New(),
+		header:     header,
+		expected:   expected,
+	}
+	return true
+}
+
+// expectedMD5 returns the header of the MD5 of the whole body of the
+// response and its value, or nil if it has none
+func expectedMD5(resp *http.Response) (string, []byte) {
+	if resp.StatusCode != http.StatusOK || resp.Body == nil || resp.Uncompressed {
+		return "", nil
+	}
+	if sum, err := base64.StdEncoding.DecodeString(resp.Header.Get("Content-Md5")); err == nil && len(sum) == md5.Size {
+		return "Content-MD5", sum
+	}
+
+	if resp.Header.Get("X-Amz-Server-Side-Encryption") == "aws:kms" ||
+		resp.Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "" {
+		return "", nil
+	}
+	// the ETags of multipart objects are "<md5 of the part md5s>-<parts>"
+	// and fail to decode
+	etag := strings.Trim(resp.Header.Get("Etag"), `"`)
+	if sum, err := hex.DecodeString(etag); err == nil && len(sum) == md5.Size {
+		return "ETag", sum
+	}
+	return "", nil
+}
+
+type verifyingBody struct {
+	io.ReadCloser
+	hash     hash.Hash
+	header   string
+	expected []byte
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := b.hash.Sum(nil); !bytes.Equal(actual, b.expected) {
+			return n, &IntegrityError{
+				Header:   b.header,
+				Expected: b.encode(b.expected),
+				Actual:   b.encode(actual),
+			}
+		}
+	}
+	return n, err
+}
+
+// encode formats the MD5 like the header it is compared to
+func (b *verifyingBody) encode(sum []byte) string {
+	if b.header == "ETag" {
+		return hex.EncodeToString(sum)
+	}
+	return base64.StdEncoding.EncodeToString(sum)
+}
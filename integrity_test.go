@@ -0,0 +1,62 @@
+package s3v2
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyBody(t *testing.T) {
+	assert := assert.New(t)
+
+	header := make(http.Header)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+
+	get := func() (*http.Response, bool) {
+		resp, err := http.Get(srv.URL)
+		assert.NoError(err)
+		return resp, VerifyBody(resp)
+	}
+
+	// the MD5 of "hello"
+	header.Set("Etag", `"5d41402abc4b2a76b9719d911017c592"`)
+	resp, ok := get()
+	assert.True(ok)
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(err)
+	assert.Equal("hello", string(body))
+
+	header.Set("Etag", `"7d793037a0760186574b0282f2f435e7"`)
+	resp, _ = get()
+	_, err = io.ReadAll(resp.Body)
+	var ierr *IntegrityError
+	assert.ErrorAs(err, &ierr)
+	assert.ErrorIs(err, ErrBodyIntegrity)
+	assert.Equal("ETag", ierr.Header)
+	assert.Equal("5d41402abc4b2a76b9719d911017c592", ierr.Actual)
+
+	// Content-MD5 is checked before the ETag
+	header.Set("Content-Md5", "XUFAKrxLKna5cZ2REBfFkg==")
+	resp, _ = get()
+	_, err = io.ReadAll(resp.Body)
+	assert.NoError(err)
+
+	// multipart and SSE-KMS ETags aren't MD5s of the body
+	header.Del("Content-Md5")
+	header.Set("Etag", `"7d793037a0760186574b0282f2f435e7-2"`)
+	_, ok = get()
+	assert.False(ok)
+	header.Set("Etag", `"7d793037a0760186574b0282f2f435e7"`)
+	header.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+	_, ok = get()
+	assert.False(ok)
+}
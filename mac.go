@@ -0,0 +1,40 @@
+package s3v2
+
+import "crypto/hmac"
+
+// MACSigner computes the MAC of a string to sign, the raw signature before
+// it is base64 encoded. Implementations backed by an HSM, KMS or Vault
+// keep the secret key out of process memory.
+type MACSigner interface {
+	Sum(stringToSign []byte) ([]byte, error)
+}
+
+// HMACSigner is a MACSigner that computes the HMAC with a secret in
+// memory, the way requests are signed without a MACSigner
+type HMACSigner struct {
+	Secret string
+	// Method is the signature method, HmacSHA1 if unset
+	Method SignatureMethod
+}
+
+// Sum implements MACSigner
+func (s HMACSigner) Sum(stringToSign []byte) ([]byte, error) {
+	method := s.Method
+	if method.Hash == nil {
+		method = HmacSHA1
+	}
+	h := hmac.New(method.Hash, []byte(s.Secret))
+	h.Write(stringToSign)
+	return h.Sum(nil), nil
+}
+
+// WithMACSigner signs requests with the MAC computed by m instead of the
+// secret access key of the credentials, which then only need the access
+// key id and can use any placeholder as the secret. The signature method
+// of m should match WithSignatureMethod when that is set, since it labels
+// the signature.
+func WithMACSigner(m MACSigner) Option {
+	return func(v2 *signer) {
+		v2.MACSigner = m
+	}
+}
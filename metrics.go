@@ -0,0 +1,52 @@
+package s3v2
+
+import "time"
+
+// Metrics records the signing and verification of requests, such as in
+// Prometheus (see the s3v2prom package). Methods are called concurrently.
+type Metrics interface {
+	// SignAttempted is called for every request signed or presigned
+	SignAttempted()
+	// SignFailed is called when signing a request fails
+	SignFailed(err error)
+	// SignLatency is called with the time signing a request took
+	SignLatency(d time.Duration)
+	// Presigned is called for every presigned request issued
+	Presigned()
+	// VerifyFailed is called when a request fails verification, with the
+	// S3 error code of the failure as the reason, such as
+	// SignatureDoesNotMatch
+	VerifyFailed(reason string)
+}
+
+// nopMetrics is the Metrics used when none is set
+type nopMetrics struct{}
+
+func (nopMetrics) SignAttempted()            {}
+func (nopMetrics) SignFailed(error)          {}
+func (nopMetrics) SignLatency(time.Duration) {}
+func (nopMetrics) Presigned()                {}
+func (nopMetrics) VerifyFailed(string)       {}
+
+// WithMetrics records the requests signed in m
+func WithMetrics(m Metrics) Option {
+	return func(v2 *signer) {
+		v2.Metrics = m
+	}
+}
+
+// WithVerifierMetrics records the verification failures in m
+func WithVerifierMetrics(m Metrics) VerifierOption {
+	return func(v *Verifier) {
+		if m != nil {
+			v.metrics = m
+		}
+	}
+}
+
+func (v2 *signer) metrics() Metrics {
+	if v2.Metrics == nil {
+		return nopMetrics{}
+	}
+	return v2.Metrics
+}
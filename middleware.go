@@ -0,0 +1,51 @@
+package s3v2
+
+import (
+	"context"
+	"net/http"
+)
+
+// RequireAuth returns a handler that only passes requests with a valid V2
+// signature, either in the Authorization header or presigned in the query
+// string, on to next. Other requests are rejected with an S3 style XML
+// error.
+func RequireAuth(lookup SecretLookup, next http.Handler) http.Handler {
+	return NewVerifier(lookup).Middleware(next)
+}
+
+// Middleware returns a handler that verifies requests before passing them
+// on to next, and rejects requests that fail verification with an S3 style
+// XML error. The credential that signed the request is available to next
+// with CredentialFromContext, and what it was signed for with
+// VerifiedRequestFromContext. Verified requests the Authorizer of
+// WithAuthorizer denies are rejected with an AccessDenied error. CORS
+// preflight requests are passed on unverified with
+// WithPreflightPassThrough.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v.preflight && IsPreflight(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		result, err := v.VerifyRequest(r)
+		if err == nil {
+			err = v.authorize(result.Request)
+		}
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), credentialContextKey{}, result.Credential)
+		ctx = context.WithValue(ctx, verifiedRequestContextKey{}, result.Request)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type credentialContextKey struct{}
+
+// CredentialFromContext returns the credential that signed the request
+// verified by the Verifier middleware
+func CredentialFromContext(ctx context.Context) (Credential, bool) {
+	cred, ok := ctx.Value(credentialContextKey{}).(Credential)
+	return cred, ok
+}
@@ -0,0 +1,154 @@
+package s3v2
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// PathEncoding is how the percent-encoding of the path is canonicalized
+type PathEncoding int
+
+const (
+	// PathAsIs signs the path with the percent-encoding it is sent with
+	PathAsIs PathEncoding = iota
+	// PathNormalize signs the path re-encoded the canonical way: bytes
+	// other than unreserved characters and "/" are escaped with upper case
+	// hex, and escaped unreserved characters are unescaped. An escaped "/"
+	// stays escaped. This matches servers, and some SDKs, that decode and
+	// re-encode the path before computing the signature, so "%7e" and "~"
+	// or "%2a" and "*" sign the same.
+	PathNormalize
+)
+
+// WithPathEncoding canonicalizes the percent-encoding of the path as
+// encoding says, PathAsIs if not set. The request is sent as-is either way.
+func WithPathEncoding(encoding PathEncoding) Option {
+	return func(v2 *signer) {
+		v2.PathEncoding = encoding
+	}
+}
+
+// WithVerifierPathEncoding verifies requests signed with WithPathEncoding
+func WithVerifierPathEncoding(encoding PathEncoding) VerifierOption {
+	return func(v *Verifier) {
+		v.pathEncoding = encoding
+	}
+}
+
+// normalizePathEncoding re-encodes the escaped path the canonical way of
+// PathNormalize
+func normalizePathEncoding(path string) string {
+	var b strings.Builder
+	b.Grow(len(path))
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		escaped := false
+		if c == '%' && i+2 < len(path) && isHex(path[i+1]) && isHex(path[i+2]) {
+			c = unhex(path[i+1])<<4 | unhex(path[i+2])
+			escaped = true
+			i += 2
+		}
+		if isUnreserved(c) || c == '/' && !escaped {
+			b.WriteByte(c)
+			continue
+		}
+		writeEscaped(&b, c)
+	}
+	return b.String()
+}
+
+// isUnreserved reports whether c is an unreserved character of RFC 3986
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// escapeUnreserved escapes every byte of s but the unreserved characters,
+// and "/" when keepSlash is set, the way the SDK escapes keys
+func escapeUnreserved(s string, keepSlash bool) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isUnreserved(c) || keepSlash && c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		writeEscaped(&b, c)
+	}
+	return b.String()
+}
+
+// writeEscaped writes c percent-encoded with upper case hex
+func writeEscaped(b *strings.Builder, c byte) {
+	const upperHex = "0123456789ABCDEF"
+	b.WriteByte('%')
+	b.WriteByte(upperHex[c>>4])
+	b.WriteByte(upperHex[c&15])
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// KeyNormalization is the Unicode normalization form keys are signed in
+type KeyNormalization int
+
+const (
+	// KeyAsIs signs keys as they are sent
+	KeyAsIs KeyNormalization = iota
+	// KeyNFC signs keys in the composed form servers usually store
+	KeyNFC
+	// KeyNFD signs keys in the decomposed form macOS clients send
+	KeyNFD
+)
+
+// WithKeyNormalization signs the path in the Unicode normalization form
+// form, KeyAsIs if not set, so a key signs the same whether it was
+// typed on macOS, which decomposes accented characters, or elsewhere. The
+// request is sent as-is, so the server must verify with the same form,
+// such as with WithVerifierKeyNormalization.
+func WithKeyNormalization(form KeyNormalization) Option {
+	return func(v2 *signer) {
+		v2.KeyNormalization = form
+	}
+}
+
+// WithVerifierKeyNormalization verifies requests signed with
+// WithKeyNormalization, and requests whose keys are sent in another form
+// than they were signed in. Requests signed with the key as it is sent,
+// such as by stock macOS clients, are still accepted.
+func WithVerifierKeyNormalization(form KeyNormalization) VerifierOption {
+	return func(v *Verifier) {
+		v.keyNormalization = form
+	}
+}
+
+// normalizeKey returns the escaped path with the segments that aren't in
+// the normalization form re-encoded in it. Segments already in the form
+// keep their encoding.
+func normalizeKey(path string, form KeyNormalization) string {
+	f := norm.NFC
+	if form == KeyNFD {
+		f = norm.NFD
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil || f.IsNormalString(unescaped) {
+			continue
+		}
+		segments[i] = escapeUnreserved(f.String(unescaped), false)
+	}
+	return strings.Join(segments, "/")
+}
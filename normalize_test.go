@@ -0,0 +1,116 @@
+package s3v2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePathEncoding(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/photos/puppy.jpg", "/photos/puppy.jpg"},
+		{"/photos/%7epuppy%2a.jpg", "/photos/~puppy%2A.jpg"},
+		{"/photos/puppy%20dog.jpg", "/photos/puppy%20dog.jpg"},
+		{"/photos/puppy%2fdog.jpg", "/photos/puppy%2Fdog.jpg"},
+		{"/photos/puppy(1)!.jpg", "/photos/puppy%281%29%21.jpg"},
+		{"/photos/%e5%ad%90%41.jpg", "/photos/%E5%AD%90A.jpg"},
+		{"/photos/100%.jpg", "/photos/100%25.jpg"},
+	}
+	for _, test := range tests {
+		assert.Equal(test.expected, normalizePathEncoding(test.path), test.path)
+	}
+}
+
+func TestSignPathEncoding(t *testing.T) {
+	assert := assert.New(t)
+
+	newRequest := func(path string) *http.Request {
+		req, _ := http.NewRequest("GET", "https://johnsmith.s3.amazonaws.com"+path, nil)
+		req.Header.Set("Date", "Tue, 27 Mar 2007 19:36:42 +0000")
+		return req
+	}
+
+	// as-is, the encodings sign differently
+	asIs, err := newTestSigner().Build(newRequest("/photos/%7epuppy(1).jpg"))
+	assert.NoError(err)
+	assert.Equal("/johnsmith/photos/%7epuppy(1).jpg", asIs.CanonicalResource)
+
+	// normalized, every encoding of the key signs the same
+	signer := newTestSigner(WithPathEncoding(PathNormalize))
+	for _, path := range []string{"/photos/%7epuppy(1).jpg", "/photos/~puppy%281%29.jpg", "/photos/%7Epuppy%28%31%29.jpg"} {
+		result, err := signer.Build(newRequest(path))
+		assert.NoError(err, path)
+		assert.Equal("/johnsmith/photos/~puppy%281%29.jpg", result.CanonicalResource, path)
+	}
+
+	// the request is sent as-is, and verified normalized
+	r := httptest.NewRequest("GET", "https://johnsmith.s3.amazonaws.com/photos/%7epuppy(1).jpg", nil)
+	r.Header.Set("Date", "Tue, 27 Mar 2007 19:36:42 +0000")
+	_, err = signer.Sign(r)
+	assert.NoError(err)
+	assert.Equal("/photos/%7epuppy(1).jpg", r.URL.EscapedPath())
+	verifier := NewVerifier(testSecretLookup, WithMaxClockSkew(0), WithVerifierPathEncoding(PathNormalize))
+	_, err = verifier.Verify(r)
+	assert.NoError(err)
+	_, err = NewVerifier(testSecretLookup, WithMaxClockSkew(0)).Verify(r)
+	assert.ErrorIs(err, ErrSignatureMismatch)
+}
+
+func TestSignKeyNormalization(t *testing.T) {
+	assert := assert.New(t)
+
+	// "café" composed, and decomposed as macOS sends it
+	const nfc, nfd = "/caf%C3%A9.txt", "/cafe%CC%81.txt"
+	newRequest := func(path string) *http.Request {
+		req := httptest.NewRequest("GET", "https://johnsmith.s3.amazonaws.com"+path, nil)
+		req.Header.Set("Date", "Tue, 27 Mar 2007 19:36:42 +0000")
+		return req
+	}
+
+	for _, test := range []struct {
+		form     KeyNormalization
+		resource string
+	}{
+		{KeyNFC, "/johnsmith" + nfc},
+		{KeyNFD, "/johnsmith" + nfd},
+	} {
+		signer := newTestSigner(WithKeyNormalization(test.form))
+		verifier := NewVerifier(testSecretLookup, WithMaxClockSkew(0), WithVerifierKeyNormalization(test.form))
+		for _, path := range []string{nfc, nfd} {
+			result, err := signer.Build(newRequest(path))
+			assert.NoError(err, path)
+			assert.Equal(test.resource, result.CanonicalResource, path)
+
+			// signed in one form and sent in the other
+			r := newRequest(path)
+			_, err = signer.Sign(r)
+			assert.NoError(err)
+			other := newRequest(map[string]string{nfc: nfd, nfd: nfc}[path])
+			other.Header = r.Header
+			_, err = verifier.Verify(other)
+			assert.NoError(err, path)
+
+			// signed as-is, in either form
+			r = newRequest(path)
+			_, err = newTestSigner().Sign(r)
+			assert.NoError(err)
+			_, err = verifier.Verify(r)
+			assert.NoError(err, path)
+		}
+	}
+
+	// as-is, the forms sign differently
+	result, err := newTestSigner().Build(newRequest(nfd))
+	assert.NoError(err)
+	assert.Equal("/johnsmith"+nfd, result.CanonicalResource)
+	assert.Equal("/caf%C3%A9/a%20b", normalizeKey("/cafe%CC%81/a%20b", KeyNFC))
+	// an escaped "/" stays escaped when its segment is re-encoded
+	assert.Equal("/a%2Fcaf%C3%A9", normalizeKey("/a%2Fcafe%CC%81", KeyNFC))
+}
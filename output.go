@@ -0,0 +1,81 @@
+package s3v2
+
+import (
+	"net/url"
+	"time"
+)
+
+// Output is where the signature of a signed request is sent
+type Output int
+
+const (
+	// OutputHeader sends the signature in the Authorization header
+	OutputHeader Output = iota
+	// OutputQuery sends the signature in the AWSAccessKeyId, Expires and
+	// Signature query parameters, like a presigned request, for legacy
+	// appliances that only accept query string authentication
+	OutputQuery
+)
+
+// QueryAuthExpiry is how long after the signing time requests signed with
+// OutputQuery expire
+const QueryAuthExpiry = 15 * time.Minute
+
+// WithOutput sends the signature of signed requests to output,
+// OutputHeader if not set. Requests signed with OutputQuery have the
+// parameters authenticating them added to their URL, replacing those of
+// an earlier signing, and expire QueryAuthExpiry after the signing time.
+func WithOutput(output Output) Option {
+	return func(v2 *signer) {
+		v2.Output = output
+	}
+}
+
+// signResult signs the request to the output of v2, and returns the
+// values computed to sign it without applying them
+func (v2 *signer) signResult() (SigningResult, error) {
+	if v2.Output != OutputQuery {
+		if err := v2.Sign(); err != nil || v2.anonymous {
+			return SigningResult{}, err
+		}
+		return v2.result(), nil
+	}
+
+	m := v2.metrics()
+	m.SignAttempted()
+	start := time.Now()
+	query, err := v2.signInQuery()
+	m.SignLatency(time.Since(start))
+	if err != nil {
+		m.SignFailed(err)
+		return SigningResult{}, err
+	}
+	if v2.anonymous {
+		return SigningResult{}, nil
+	}
+
+	result := v2.result()
+	result.Authorization, result.Date, result.DateHeader = "", "", ""
+	result.Query = query
+	return result, nil
+}
+
+// signInQuery returns the query parameters authenticating the request
+// until QueryAuthExpiry after the signing time
+func (v2 *signer) signInQuery() (url.Values, error) {
+	if err := checkRequest(v2.Request); err != nil {
+		return nil, err
+	}
+	// the signature of an earlier signing, such as of a retry, is not part
+	// of the signed request
+	v2.Request.URL.RawQuery = stripQueryParams(v2.Request.URL.RawQuery, presignQueryParams)
+	if err := v2.checkConflictingAuth(); err != nil {
+		return nil, err
+	}
+
+	signingTime := v2.Time
+	if signingTime.IsZero() {
+		signingTime = v2.now().Add(v2.ClockOffset)
+	}
+	return v2.signQuery(signingTime.Add(QueryAuthExpiry))
+}
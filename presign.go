@@ -0,0 +1,230 @@
+package s3v2
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxPartNumber is the largest part number of a multipart upload
+const MaxPartNumber = 10000
+
+// DefaultPresignExpiry is how long URLs built by PresignRequest are valid
+// for when no expiry is set
+const DefaultPresignExpiry = 15 * time.Minute
+
+// PresignRequest builds the presigned URL of an object, with options set
+// by its chained methods:
+//
+//	u, err := signer.PresignObject("bucket", "photos/puppy.jpg").
+//		Expires(time.Hour).
+//		ResponseHeader("Content-Disposition", "attachment").
+//		URL()
+type PresignRequest struct {
+	signer    *Signer
+	method    string
+	bucket    string
+	key       string
+	endpoint  string
+	pathStyle bool
+	expires   time.Time
+	expiry    time.Duration
+	query     url.Values
+	header    http.Header
+}
+
+// PresignObject returns a PresignRequest of a GET of the key in bucket,
+// on the AWS S3 endpoint, valid for DefaultPresignExpiry
+func (s *Signer) PresignObject(bucket, key string) *PresignRequest {
+	return &PresignRequest{
+		signer:   s,
+		method:   http.MethodGet,
+		bucket:   bucket,
+		key:      key,
+		endpoint: "https://s3.amazonaws.com",
+		expiry:   DefaultPresignExpiry,
+		query:    make(url.Values),
+		header:   make(http.Header),
+	}
+}
+
+// PresignUploadPart returns a PresignRequest of the PUT of part partNumber
+// of the multipart upload uploadID of the key in bucket, for handing to
+// upload workers without credentials. The ETag of the response is needed
+// to complete the upload.
+func (s *Signer) PresignUploadPart(bucket, key, uploadID string, partNumber int) *PresignRequest {
+	p := s.PresignObject(bucket, key).Method(http.MethodPut)
+	p.query.Set("partNumber", strconv.Itoa(partNumber))
+	p.query.Set("uploadId", uploadID)
+	return p
+}
+
+// PresignTorrent returns a PresignRequest of a GET of the torrent file of
+// the key in bucket, valid for DefaultPresignExpiry
+func (s *Signer) PresignTorrent(bucket, key string) *PresignRequest {
+	return s.PresignObject(bucket, key).Torrent()
+}
+
+// Torrent makes the URL address the torrent file of the object instead of
+// the object. The torrent subresource is only served to GETs.
+func (p *PresignRequest) Torrent() *PresignRequest {
+	p.query.Set("torrent", "")
+	return p
+}
+
+// Method sets the method the URL is valid for, such as PUT for uploads
+func (p *PresignRequest) Method(method string) *PresignRequest {
+	p.method = method
+	return p
+}
+
+// Expires sets how long the URL is valid for, from when URL is called
+func (p *PresignRequest) Expires(d time.Duration) *PresignRequest {
+	p.expiry = d
+	p.expires = time.Time{}
+	return p
+}
+
+// ExpiresAt sets when the URL stops being valid
+func (p *PresignRequest) ExpiresAt(t time.Time) *PresignRequest {
+	p.expires = t
+	return p
+}
+
+// Endpoint sets the endpoint of the service, such as
+// "http://localhost:9000"
+func (p *PresignRequest) Endpoint(endpoint string) *PresignRequest {
+	p.endpoint = endpoint
+	return p
+}
+
+// PathStyle addresses the bucket in the path of the URL instead of the
+// host. Buckets that can't be virtual hosts, and IP address endpoints, are
+// always addressed in the path.
+func (p *PresignRequest) PathStyle() *PresignRequest {
+	p.pathStyle = true
+	return p
+}
+
+// VersionID makes the URL address a version of the object
+func (p *PresignRequest) VersionID(versionID string) *PresignRequest {
+	p.query.Set("versionId", versionID)
+	return p
+}
+
+// ResponseHeader overrides a header of the GET response, one of
+// Cache-Control, Content-Disposition, Content-Encoding, Content-Language,
+// Content-Type or Expires
+func (p *PresignRequest) ResponseHeader(name, value string) *PresignRequest {
+	p.query.Set("response-"+strings.ToLower(name), value)
+	return p
+}
+
+// Header sets a header the URL must be sent with, such as the
+// Content-Type of an upload
+func (p *PresignRequest) Header(name, value string) *PresignRequest {
+	p.header.Set(name, value)
+	return p
+}
+
+// URL returns the presigned URL, with the key and query escaped the way
+// they are signed
+func (p *PresignRequest) URL() (*url.URL, error) {
+	for name := range p.query {
+		if !subResources[name] {
+			return nil, fmt.Errorf("s3v2: %s can't be set on a presigned URL", name)
+		}
+	}
+	if _, ok := p.query["torrent"]; ok && p.method != http.MethodGet {
+		return nil, fmt.Errorf("s3v2: torrent can't be presigned for %s", p.method)
+	}
+	if partNumber := p.query.Get("partNumber"); partNumber != "" {
+		if n, err := strconv.Atoi(partNumber); err != nil || n < 1 || n > MaxPartNumber {
+			return nil, fmt.Errorf("s3v2: part number %s is not between 1 and %d", partNumber, MaxPartNumber)
+		}
+	}
+
+	u, err := ObjectURL(p.endpoint, p.bucket, p.key, p.pathStyle)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = encodeSubResources(p.query)
+
+	req, err := http.NewRequest(p.method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = p.header.Clone()
+
+	expires := p.expires
+	if expires.IsZero() {
+		v2 := p.signer.newSigner(req)
+		expires = v2.now().Add(p.expiry)
+	}
+
+	signer := &Signer{
+		provider: p.signer.provider,
+		opts:     append(append([]Option(nil), p.signer.opts...), WithBucket(p.bucket)),
+	}
+	return signer.Presign(req, expires)
+}
+
+// encodeSubResources encodes the query sorted by name like url.Values, but
+// with the subresources without a value, such as torrent, bare the way S3
+// documents them
+func encodeSubResources(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		for _, value := range query[name] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(name))
+			if value != "" {
+				b.WriteByte('=')
+				b.WriteString(url.QueryEscape(value))
+			}
+		}
+	}
+	return b.String()
+}
+
+// ObjectURL returns the URL of the key in bucket at the endpoint, with the
+// bucket in the host unless pathStyle is set or the bucket can't be a
+// virtual host. The key is escaped the way S3 expects.
+func ObjectURL(endpoint, bucket, key string, pathStyle bool) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("s3v2: endpoint %q is not an absolute URL", endpoint)
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("s3v2: missing bucket")
+	}
+
+	base := strings.TrimSuffix(u.Path, "/")
+	path := "/" + key
+	if pathStyle || !hostCompatibleBucketName(u, bucket) || net.ParseIP(u.Hostname()) != nil {
+		path = "/" + bucket + path
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	u.Path = base + path
+	u.RawPath = escapeUnreserved(base, true) + escapeUnreserved(path, true)
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u, nil
+}
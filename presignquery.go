@@ -0,0 +1,88 @@
+package s3v2
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// PresignParams are the query parameters that authenticate a presigned
+// request
+type PresignParams struct {
+	AccessKeyID string
+	Expires     time.Time
+	Signature   string
+	// SecurityToken is the session token of temporary credentials, if
+	// the request was presigned with them
+	SecurityToken string
+}
+
+// PresignQueryError is returned by ParsePresignedQuery when a parameter is
+// missing or invalid. It wraps ErrMalformedPresign.
+type PresignQueryError struct {
+	Param  string
+	Value  string
+	Reason string
+}
+
+func (e *PresignQueryError) Error() string {
+	return "s3v2: presigned query parameter " + e.Param + " " + e.Reason
+}
+
+func (e *PresignQueryError) Unwrap() error {
+	return ErrMalformedPresign
+}
+
+// ParsePresignedQuery parses the parameters of a presigned request from
+// its query. AWSAccessKeyId, Expires and Signature must each be set once,
+// Expires to seconds since the epoch and Signature to a base64 value, and
+// x-amz-security-token at most once.
+func ParsePresignedQuery(query url.Values) (PresignParams, error) {
+	var params PresignParams
+	var err error
+	if params.AccessKeyID, err = singleParam(query, "AWSAccessKeyId", true); err != nil {
+		return PresignParams{}, err
+	}
+
+	expires, err := singleParam(query, "Expires", true)
+	if err != nil {
+		return PresignParams{}, err
+	}
+	seconds, perr := strconv.ParseUint(expires, 10, 63)
+	if perr != nil {
+		return PresignParams{}, &PresignQueryError{Param: "Expires", Value: expires,
+			Reason: "is not seconds since the epoch"}
+	}
+	params.Expires = time.Unix(int64(seconds), 0)
+
+	if params.Signature, err = singleParam(query, "Signature", true); err != nil {
+		return PresignParams{}, err
+	}
+	if _, err := base64.StdEncoding.DecodeString(params.Signature); err != nil {
+		return PresignParams{}, &PresignQueryError{Param: "Signature", Value: params.Signature,
+			Reason: "is not base64"}
+	}
+
+	if params.SecurityToken, err = singleParam(query, "x-amz-security-token", false); err != nil {
+		return PresignParams{}, err
+	}
+	return params, nil
+}
+
+// singleParam returns the value of the query parameter, which must not be
+// repeated or empty, and must be set if it is required
+func singleParam(query url.Values, name string, required bool) (string, error) {
+	values, ok := query[name]
+	switch {
+	case !ok && required:
+		return "", &PresignQueryError{Param: name, Reason: "is missing"}
+	case !ok:
+		return "", nil
+	case len(values) > 1:
+		return "", &PresignQueryError{Param: name, Reason: "is repeated"}
+	case values[0] == "":
+		return "", &PresignQueryError{Param: name, Reason: "is empty"}
+	}
+	return values[0], nil
+}
@@ -0,0 +1,89 @@
+package s3v2
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Profile bundles the options for signing requests to an S3 compatible
+// service with the V2 signature, and the endpoint of the service
+type Profile struct {
+	Name string
+	// Endpoint is the endpoint of the service, if it has a public one
+	Endpoint string
+	// Region is the region SDK clients of the service use, if it has
+	// one
+	Region string
+	// PathStyle is set when the service only addresses buckets path
+	// style
+	PathStyle bool
+	Options   []Option
+}
+
+// Option returns an Option applying the options of the profile, for use
+// with NewSigner or the awsv1 package alongside other options
+func (p Profile) Option() Option {
+	opts := append([]Option(nil), p.Options...)
+	if p.PathStyle {
+		opts = append(opts, WithPathStyle(true))
+	}
+	return func(v2 *signer) {
+		for _, opt := range opts {
+			opt(v2)
+		}
+	}
+}
+
+// ProfileGoogleLegacy signs requests for the interoperability API of
+// Google Cloud Storage: x-goog headers are the canonical headers, the
+// Authorization is labeled GOOG1, and buckets are found in hosts under
+// storage.googleapis.com. Servers verify with
+// WithVerifierHeaderPrefixes("x-goog"), WithVerifierAuthScheme("GOOG1") and
+// WithVerifierEndpointSuffix("storage.googleapis.com").
+var ProfileGoogleLegacy = Profile{
+	Name:     "google-legacy",
+	Endpoint: "https://storage.googleapis.com",
+	Options: []Option{
+		WithHeaderPrefixes("x-goog"),
+		WithAuthScheme("GOOG1"),
+		WithEndpointSuffix("storage.googleapis.com"),
+	},
+}
+
+// ProfileCephRGW signs requests for the S3 API of Ceph RGW, with the
+// subresources of appendable objects, ?append and ?position, that RGW signs
+// beyond those of S3. Buckets are addressed virtual host or path style as
+// the request is sent. Tenant qualified buckets, "tenant:bucket", can't be
+// put in a host, so they are always path style. Servers verify with
+// WithVerifierSubResources("append", "position").
+var ProfileCephRGW = Profile{
+	Name: "ceph-rgw",
+	Options: []Option{
+		WithSubResources("append", "position"),
+	},
+}
+
+// ProfileMinIO returns the profile of a MinIO style deployment at
+// endpoint, such as "http://localhost:9000" or "10.0.0.5:9000", where
+// buckets are always addressed path style whatever the host or port. An
+// endpoint without a scheme is http, and clients use the us-east-1 region
+// MinIO defaults to.
+func ProfileMinIO(endpoint string) (Profile, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return Profile{}, fmt.Errorf("s3v2: invalid endpoint: %w", err)
+	}
+	if u.Host == "" {
+		return Profile{}, &URLError{URL: endpoint, Err: ErrMissingHost}
+	}
+	return Profile{
+		Name:      "minio",
+		Endpoint:  u.Scheme + "://" + u.Host,
+		Region:    "us-east-1",
+		PathStyle: true,
+	}, nil
+}
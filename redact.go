@@ -0,0 +1,70 @@
+package s3v2
+
+import "strings"
+
+// Redacted replaces secrets in debug output
+const Redacted = "REDACTED"
+
+// RedactAuthorization returns the Authorization header with its signature
+// redacted, for proxies that log request headers. The access key id is
+// kept so the log still shows who signed the request. Headers that can't
+// be parsed are redacted entirely.
+func RedactAuthorization(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	// V4 style "Credential=..., SignedHeaders=..., Signature=..."
+	if i := strings.Index(header, "Signature="); i >= 0 {
+		rest := header[i+len("Signature="):]
+		end := strings.IndexAny(rest, ", ")
+		if end < 0 {
+			end = len(rest)
+		}
+		return header[:i] + "Signature=" + Redacted + rest[end:]
+	}
+
+	// V2 style "AWS AccessKeyId:Signature", with any scheme name
+	scheme, credential, ok := strings.Cut(header, " ")
+	if !ok {
+		return Redacted
+	}
+	i := strings.LastIndex(credential, ":")
+	if i <= 0 {
+		return Redacted
+	}
+	return scheme + " " + credential[:i+1] + Redacted
+}
+
+// redactor removes the secret access key and session token of credentials
+// from debug output
+type redactor struct {
+	secrets []string
+}
+
+func newRedactor(credValue credentialsValue) redactor {
+	var r redactor
+	for _, secret := range []string{credValue.SecretAccessKey, credValue.SessionToken} {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	return r
+}
+
+// redact returns s with the session token line of a string to sign, and
+// any other occurrence of the secrets, redacted
+func (r redactor) redact(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "x-amz-security-token:") {
+			lines[i] = "x-amz-security-token:" + Redacted
+		}
+	}
+	s = strings.Join(lines, "\n")
+
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+package s3v2
+
+import (
+	"container/heap"
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ReplayGuard remembers the signatures of verified requests, so a gateway
+// can refuse requests that are replayed within the clock skew window
+type ReplayGuard interface {
+	// Seen records the signature of a request made at date, and reports
+	// whether the signature was already recorded. Requests too old for
+	// their signature to still be recorded are reported as seen.
+	Seen(signature string, date time.Time) bool
+}
+
+// WithReplayGuard rejects requests signed in the Authorization header that
+// guard has already seen. Presigned requests are not checked since
+// presigned URLs are meant to be used until they expire.
+func WithReplayGuard(guard ReplayGuard) VerifierOption {
+	return func(v *Verifier) {
+		v.replayGuard = guard
+	}
+}
+
+// MemoryReplayGuard is an in-memory ReplayGuard that remembers the most
+// recent signatures, up to a maximum number. Signatures of requests older
+// than the window are forgotten, so requests older than the window are
+// reported as seen: a replay of them could not be told apart, even when
+// the clock skew check is disabled or allows more than the window.
+type MemoryReplayGuard struct {
+	mu      sync.Mutex
+	size    int
+	window  time.Duration
+	entries map[string]*replayEntry
+	// order is the entries from the most to the least recently seen, and
+	// dates the entries by the date of their request, oldest first
+	order *list.List
+	dates replayDates
+	clock Clock
+}
+
+type replayEntry struct {
+	signature string
+	date      time.Time
+	elem      *list.Element
+	// index is the index of the entry in dates
+	index int
+}
+
+// NewMemoryReplayGuard returns a MemoryReplayGuard that remembers up to size
+// signatures of requests made within window of the current time of clock,
+// or SystemClock if it is nil. A size of 0 or less remembers every
+// signature within the window.
+func NewMemoryReplayGuard(size int, window time.Duration, clock Clock) *MemoryReplayGuard {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &MemoryReplayGuard{
+		size:    size,
+		window:  window,
+		entries: make(map[string]*replayEntry),
+		order:   list.New(),
+		clock:   clock,
+	}
+}
+
+// Seen implements ReplayGuard
+func (g *MemoryReplayGuard) Seen(signature string, date time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cutoff := g.clock.Now().Add(-g.window)
+	g.expire(cutoff)
+	if date.Before(cutoff) {
+		return true
+	}
+
+	if entry, ok := g.entries[signature]; ok {
+		g.order.MoveToFront(entry.elem)
+		return true
+	}
+
+	entry := &replayEntry{signature: signature, date: date}
+	entry.elem = g.order.PushFront(entry)
+	heap.Push(&g.dates, entry)
+	g.entries[signature] = entry
+	for g.size > 0 && g.order.Len() > g.size {
+		g.remove(g.order.Back().Value.(*replayEntry))
+	}
+	return false
+}
+
+// expire forgets the signatures of requests made before cutoff, stopping
+// at the oldest request within the window
+func (g *MemoryReplayGuard) expire(cutoff time.Time) {
+	for len(g.dates) > 0 && g.dates[0].date.Before(cutoff) {
+		g.remove(g.dates[0])
+	}
+}
+
+func (g *MemoryReplayGuard) remove(entry *replayEntry) {
+	g.order.Remove(entry.elem)
+	heap.Remove(&g.dates, entry.index)
+	delete(g.entries, entry.signature)
+}
+
+// replayDates is a heap of replay entries by the date of their request
+type replayDates []*replayEntry
+
+func (d replayDates) Len() int           { return len(d) }
+func (d replayDates) Less(i, j int) bool { return d[i].date.Before(d[j].date) }
+
+func (d replayDates) Swap(i, j int) {
+	d[i], d[j] = d[j], d[i]
+	d[i].index = i
+	d[j].index = j
+}
+
+func (d *replayDates) Push(x any) {
+	entry := x.(*replayEntry)
+	entry.index = len(*d)
+	*d = append(*d, entry)
+}
+
+func (d *replayDates) Pop() any {
+	old := *d
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*d = old[:len(old)-1]
+	return entry
+}
@@ -0,0 +1,208 @@
+package s3v2
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// Resigner is the core of an S3 gateway: it verifies the V2 signature of
+// requests signed with tenant credentials, and re-signs them with the
+// credentials of the backend they are forwarded to. A Resigner is safe for
+// concurrent use.
+type Resigner struct {
+	verifier *Verifier
+	backend  *url.URL
+	// sign signs the request for the backend
+	sign func(out *http.Request) error
+}
+
+// NewResigner returns a Resigner that verifies requests with verifier and
+// forwards them to the scheme and host of backend, signed with the keys of
+// provider configured by opts. Requests are forwarded path style, under the path of
+// backend if it has one, whether they were sent virtual host or path style.
+func NewResigner(verifier *Verifier, backend *url.URL, provider CredentialsProvider, opts ...Option) *Resigner {
+	opts = append(append([]Option(nil), opts...), WithPathStyle(true))
+	signer := NewSigner(provider, opts...)
+	return NewResignerFunc(verifier, backend, func(out *http.Request) error {
+		_, err := signer.Sign(out)
+		return err
+	})
+}
+
+// NewResignerFunc returns a Resigner that verifies requests with verifier
+// and forwards them to backend like NewResigner, signed for the backend by
+// sign, such as with another signature version
+func NewResignerFunc(verifier *Verifier, backend *url.URL, sign func(out *http.Request) error) *Resigner {
+	return &Resigner{verifier: verifier, backend: backend, sign: sign}
+}
+
+// Resign verifies the request and returns the request to send to the
+// backend, with the credential that signed it. The authentication of the
+// tenant, in the headers or the query string, is removed and the request is
+// signed for the backend with a fresh date. The body is shared with the
+// request, so it is streamed to the backend. A *VerifyError is returned
+// when the request is not authenticated.
+func (rs *Resigner) Resign(r *http.Request) (*http.Request, Credential, error) {
+	result, err := rs.verifier.VerifyRequest(r)
+	if err != nil {
+		return nil, Credential{}, err
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Host = ""
+	for _, name := range []string{"Authorization", "Date", "X-Amz-Date", "X-Amz-Security-Token"} {
+		out.Header.Del(name)
+	}
+	removeHopHeaders(out.Header)
+
+	out.URL, err = forwardURL(rs.backend, r, rs.verifier.pathStyle, result.Request.Bucket,
+		stripQueryParams(r.URL.RawQuery, presignQueryParams))
+	if err != nil {
+		return nil, Credential{}, err
+	}
+
+	if err := rs.sign(out); err != nil {
+		return nil, Credential{}, err
+	}
+	return out, result.Credential, nil
+}
+
+// Handler returns a handler that forwards verified requests to the backend
+// with transport, or http.DefaultTransport if it is nil, and copies the
+// response back without its hop-by-hop headers. Requests that fail verification are rejected with an S3
+// style XML error, and requests that can't be signed or sent with 502 Bad
+// Gateway.
+func (rs *Resigner) Handler(transport http.RoundTripper) http.Handler {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, _, err := rs.Resign(r)
+		var verr *VerifyError
+		if errors.As(err, &verr) {
+			WriteError(w, r, err)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		resp, err := transport.RoundTrip(out)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		removeHopHeaders(resp.Header)
+		for name, values := range resp.Header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	})
+}
+
+// presignQueryParams are the query parameters authenticating presigned
+// requests
+var presignQueryParams = map[string]bool{
+	"AWSAccessKeyId":       true,
+	"Signature":            true,
+	"Expires":              true,
+	"x-amz-security-token": true,
+}
+
+// hopHeaders are the headers that apply to a single connection, which a
+// proxy must not forward (RFC 7230 section 6.1)
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopHeaders removes the hop-by-hop headers from header, including
+// those named in its Connection header
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = textproto.TrimString(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// forwardURL returns the URL of the request r forwarded to backend path
+// style, under the path of backend, with rawQuery as its query. The bucket
+// the request was verified for, if known, tells virtual host style requests
+// from path style ones, otherwise it is guessed from the host.
+func forwardURL(backend *url.URL, r *http.Request, pathStyle bool, bucket, rawQuery string) (*url.URL, error) {
+	u := &url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath}
+	host := requestHost(r)
+	var path string
+	switch {
+	case bucket == "":
+		path = CanonicalResource(u, host, "", pathStyle)
+	case !pathStyle && bucketInHost(host, bucket):
+		// the bucket of virtual host style requests moves into the path
+		path = "/" + url.PathEscape(bucket) + u.EscapedPath()
+	default:
+		path = u.EscapedPath()
+	}
+	path = strings.TrimSuffix(backend.EscapedPath(), "/") + path
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{
+		Scheme:   backend.Scheme,
+		Host:     backend.Host,
+		Path:     unescaped,
+		RawPath:  path,
+		RawQuery: rawQuery,
+	}, nil
+}
+
+// bucketInHost reports whether a request to host names bucket in its host,
+// as the first label or, for custom domains, as the whole host
+func bucketInHost(host, bucket string) bool {
+	if isIPHost(host) {
+		return false
+	}
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host == bucket || strings.HasPrefix(host, bucket+".")
+}
+
+// stripQueryParams returns the raw query without the parameters in names,
+// keeping the order and encoding of the others
+func stripQueryParams(rawQuery string, names map[string]bool) string {
+	var kept []string
+	for rawQuery != "" {
+		var param string
+		param, rawQuery, _ = strings.Cut(rawQuery, "&")
+		name, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(name); err == nil && names[name] {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	return strings.Join(kept, "&")
+}
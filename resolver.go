@@ -0,0 +1,73 @@
+package s3v2
+
+import (
+	"net/url"
+	"strings"
+)
+
+// CredentialResolver picks the credentials of a request by its bucket or
+// host, for clients and proxies that sign for several accounts, such as
+// migration tools fanning out across them
+type CredentialResolver interface {
+	// CredentialsFor returns the credentials of requests to bucket at
+	// host, or nil to sign with the credentials of the signer. The bucket
+	// is empty for requests to the service, such as ListBuckets.
+	CredentialsFor(bucket, host string) CredentialsProvider
+}
+
+// CredentialResolverFunc is a CredentialResolver function
+type CredentialResolverFunc func(bucket, host string) CredentialsProvider
+
+// CredentialsFor implements CredentialResolver
+func (f CredentialResolverFunc) CredentialsFor(bucket, host string) CredentialsProvider {
+	return f(bucket, host)
+}
+
+// BucketCredentials is a CredentialResolver of the credentials of each
+// bucket
+type BucketCredentials map[string]CredentialsProvider
+
+// CredentialsFor implements CredentialResolver
+func (m BucketCredentials) CredentialsFor(bucket, host string) CredentialsProvider {
+	return m[bucket]
+}
+
+// WithCredentialResolver signs requests with the credentials resolver
+// returns for their bucket and host, and with the credentials of the signer
+// when it returns nil
+func WithCredentialResolver(resolver CredentialResolver) Option {
+	return func(v2 *signer) {
+		v2.CredentialResolver = resolver
+	}
+}
+
+// resolveCredentials replaces the credentials of v2 with the credentials
+// of its resolver for the request, if any
+func (v2 *signer) resolveCredentials() {
+	if v2.CredentialResolver == nil {
+		return
+	}
+	if provider := v2.CredentialResolver.CredentialsFor(v2.bucket(), v2.host()); provider != nil {
+		v2.Provider = provider
+	}
+}
+
+// bucket returns the bucket the request targets, the first segment of its
+// canonical resource without the base path
+func (v2 *signer) bucket() string {
+	unsigned := *v2
+	unsigned.BasePathMode = BasePathUnsigned
+	if v2.EndpointTemplate != nil {
+		template := *v2.EndpointTemplate
+		template.BasePathMode = BasePathUnsigned
+		unsigned.EndpointTemplate = &template
+	}
+	unsigned.buildCanonicalizedResource()
+
+	resource, _, _ := strings.Cut(unsigned.canonicalResource, "?")
+	bucket, _, _ := strings.Cut(strings.TrimPrefix(resource, "/"), "/")
+	if unescaped, err := url.PathUnescape(bucket); err == nil {
+		bucket = unescaped
+	}
+	return bucket
+}
@@ -0,0 +1,95 @@
+package s3v2
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrorDocument is the XML body of an S3 error response
+type ErrorDocument struct {
+	XMLName           xml.Name `xml:"Error"`
+	Code              string   `xml:"Code"`
+	Message           string   `xml:"Message"`
+	Resource          string   `xml:"Resource,omitempty"`
+	AWSAccessKeyID    string   `xml:"AWSAccessKeyId,omitempty"`
+	StringToSign      string   `xml:"StringToSign,omitempty"`
+	SignatureProvided string   `xml:"SignatureProvided,omitempty"`
+	StringToSignBytes string   `xml:"StringToSignBytes,omitempty"`
+	Detail            string   `xml:"Detail,omitempty"`
+	RequestTime       string   `xml:"RequestTime,omitempty"`
+	ServerTime        string   `xml:"ServerTime,omitempty"`
+	MaxAllowedSkewMs  int64    `xml:"MaxAllowedSkewMilliseconds,omitempty"`
+	RequestID         string   `xml:"RequestId,omitempty"`
+	HostID            string   `xml:"HostId,omitempty"`
+}
+
+// NewErrorDocument returns the S3 error document for a verification
+// failure on resource. Errors that are not a *VerifyError are reported as
+// AccessDenied. Signature mismatches include the string to sign, in text
+// and as bytes, the way S3 reports them.
+func NewErrorDocument(err error, resource string) ErrorDocument {
+	var verr *VerifyError
+	if !errors.As(err, &verr) {
+		verr = errMissingAuth
+	}
+
+	doc := ErrorDocument{
+		Code:              verr.Code,
+		Message:           verr.Message,
+		Resource:          resource,
+		AWSAccessKeyID:    verr.AccessKeyID,
+		StringToSign:      verr.StringToSign,
+		SignatureProvided: verr.SignatureProvided,
+		Detail:            verr.Detail,
+		RequestTime:       verr.RequestTime,
+		ServerTime:        verr.ServerTime,
+		MaxAllowedSkewMs:  verr.MaxAllowedSkew.Milliseconds(),
+	}
+	if verr.StringToSign != "" {
+		doc.StringToSignBytes = stringToSignBytes(verr.StringToSign)
+	}
+	return doc
+}
+
+// Marshal returns the document as XML, including the XML header
+func (d ErrorDocument) Marshal() ([]byte, error) {
+	body, err := xml.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// WriteError writes the verification error of the request as an S3 error
+// response
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusForbidden
+	var verr *VerifyError
+	if errors.As(err, &verr) && verr.StatusCode != 0 {
+		status = verr.StatusCode
+	}
+
+	body, merr := NewErrorDocument(err, r.URL.Path).Marshal()
+	if merr != nil {
+		http.Error(w, merr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// stringToSignBytes formats the string to sign as space separated hex
+// bytes, such as "47 45 54 0a"
+func stringToSignBytes(stringToSign string) string {
+	b := []byte(stringToSign)
+	hexBytes := make([]string, len(b))
+	for i := range b {
+		hexBytes[i] = hex.EncodeToString(b[i : i+1])
+	}
+	return strings.Join(hexBytes, " ")
+}
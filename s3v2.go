@@ -1,235 +1,1182 @@
 package s3v2
 
 import (
+	"context"
 	"crypto/hmac"
+	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
-	"sort"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/request"
 )
 
 const (
-	signatureVersion = "2"
-	signatureMethod  = "HmacSHA1"
-	timeFormat       = time.RFC1123Z
+	signatureVersion  = "2"
+	timeFormat        = time.RFC1123Z
+	defaultAuthScheme = "AWS"
+)
+
+// SignatureMethod is the HMAC algorithm used to compute the signature
+type SignatureMethod struct {
+	// Name labels the method, such as "HmacSHA1"
+	Name string
+	// Hash is the hash constructor used for the HMAC
+	Hash func() hash.Hash
+}
+
+var (
+	// HmacSHA1 is the signature method used by S3, and the default
+	HmacSHA1 = SignatureMethod{Name: "HmacSHA1", Hash: sha1.New}
+	// HmacSHA256 is accepted instead of HmacSHA1 by some S3 compatible
+	// services
+	HmacSHA256 = SignatureMethod{Name: "HmacSHA256", Hash: sha256.New}
 )
 
 type signer struct {
 	// Values that must be populated from the request
-	Request     *http.Request
-	Time        time.Time
-	Credentials *credentials.Credentials
-	Debug       aws.LogLevelType
-	Logger      aws.Logger
-	Query       url.Values
-	PathStyle   bool
+	Request *http.Request
+	Time    time.Time
+	// Provider provides the keys, the request is anonymous if it is nil
+	Provider CredentialsProvider
+	// CredentialResolver picks the credentials of the request, if set
+	CredentialResolver CredentialResolver
+	PathStyle          bool
+	// ClockOffset is added to the signing time
+	ClockOffset time.Duration
+	// Clock tells the current time when Time is not set, SystemClock if
+	// unset
+	Clock Clock
+	// Context bounds getting the credentials, the request context if unset
+	Context context.Context
+	// UseAmzDate sets the signing time in x-amz-date instead of Date
+	UseAmzDate bool
+	// ComputeContentMD5 sets Content-MD5 of PUT and POST requests
+	ComputeContentMD5 bool
+	// Body is the seekable request body, if it isn't Request.Body
+	Body io.ReadSeeker
+	// ReplaceDate dates the request with the signing time even when it
+	// already has a date
+	ReplaceDate bool
+	// SignatureMethod computes the signature, HmacSHA1 if unset
+	SignatureMethod SignatureMethod
+	// AuthScheme labels the Authorization value, "AWS" if unset
+	AuthScheme string
+	// HeaderPrefixes select the canonical headers, "x-amz" if unset
+	HeaderPrefixes []string
+	// HeaderAllowlist restricts the canonical headers to these lower
+	// case names, if set
+	HeaderAllowlist []string
+	// Bucket is the bucket the request targets, if known. It is used
+	// to tell virtual host style requests from path style ones.
+	Bucket string
+	// Host is canonicalized instead of the host of the request, if set
+	Host string
+	// BasePath is the path the S3 API is mounted under, if any, and
+	// BasePathMode whether it is part of the canonical resource
+	BasePath     string
+	BasePathMode BasePathMode
+	// BucketInResource is whether the bucket is prefixed to the
+	// canonical resource
+	BucketInResource BucketInResource
+	// EndpointTemplate describes the bucket and base path of requests to
+	// the endpoint, if set
+	EndpointTemplate *EndpointTemplate
+	// SubResources are signed as part of the canonical resource, in
+	// addition to the subresources S3 signs
+	SubResources []string
+	// EndpointSuffix is the host of the service endpoint, such as
+	// "storage.googleapis.com", used to find the bucket of virtual host
+	// style requests
+	EndpointSuffix string
+	// Slog logs the signed requests at debug level, if set
+	Slog *slog.Logger
+	// Metrics records the signing of requests, if set
+	Metrics Metrics
+	// SignHooks are called after a request is signed
+	SignHooks []SignHook
+	// AuditFunc is called with the record of every signed request
+	AuditFunc func(AuditRecord)
+	// MACSigner computes the signature instead of the secret, if set
+	MACSigner MACSigner
+	// AllowAnonymous leaves requests with anonymous credentials unsigned
+	// instead of failing
+	AllowAnonymous bool
+	// RefreshWindow refreshes credentials that expire within the window
+	// of the signing time, except those already refreshed early
+	RefreshWindow time.Duration
+	refreshed     *refreshedExpiries
+	// MaxPresignExpiry is the longest presigned requests can be valid
+	// for, DefaultMaxPresignExpiry if 0 and unlimited if negative
+	MaxPresignExpiry time.Duration
+	// Output is where the signature of signed requests is sent
+	Output Output
+	// ConflictPolicy is what signing does with authentication the
+	// request already carries
+	ConflictPolicy ConflictPolicy
+	// PathEncoding is how the percent-encoding of the path is
+	// canonicalized
+	PathEncoding PathEncoding
+	// KeyNormalization is the Unicode normalization form of the signed
+	// path
+	KeyNormalization KeyNormalization
+
+	// anonymous is set when the credentials were anonymous
+	anonymous bool
+
+	hmacPool *hmacPool
 
 	canonicalResource   string
 	canonicalAmzHeaders string
 	stringToSign        string
 	signature           string
+	authorization       string
 }
 
-// SignRequestHandler is a named request handler the SDK will use to sign
-// service client request with using the V2 signature.
-var SignRequestHandler = request.NamedHandler{
-	Name: "v2.SignRequestHandler", Fn: SignSDKRequest,
+// Option configures how requests are signed. Options are applied to the
+// state of every request signed, so state they share between requests,
+// such as the pool of WithHMACPool, must be safe for concurrent use.
+type Option func(*signer)
+
+// WithClockOffset signs requests with the local time shifted by offset.
+// This allows clients with a drifting clock to authenticate to servers
+// that enforce a maximum clock skew.
+func WithClockOffset(offset time.Duration) Option {
+	return func(v2 *signer) {
+		v2.ClockOffset = offset
+	}
 }
 
-// SignSDKRequest requests with signature version 2.
-//
-// Will sign the requests with the service config's Credentials object
-// Signing is skipped if the credentials is the credentials.AnonymousCredentials
-// object.
-//
-// This is intended to be specific to S3, for others use v2 or v4
-func SignSDKRequest(req *request.Request) {
-	// If the request does not need to be signed ignore the signing of the
-	// request if the AnonymousCredentials object is used.
-	if req.Config.Credentials == credentials.AnonymousCredentials {
-		return
+// WithPathStyle sets whether requests are signed as path style, with the
+// bucket as the first segment of the path, whatever the host looks like
+func WithPathStyle(pathStyle bool) Option {
+	return func(v2 *signer) {
+		v2.PathStyle = pathStyle
 	}
+}
 
-	v2 := signer{
-		Request:     req.HTTPRequest,
-		Credentials: req.Config.Credentials,
-		Debug:       req.Config.LogLevel.Value(),
-		Logger:      req.Config.Logger,
-		Query:       req.HTTPRequest.URL.Query(),
-		PathStyle:   *req.Config.S3ForcePathStyle,
+// WithSlog logs the method, host, canonical resource, access key id,
+// string to sign and signature of every signed request to logger, at debug
+// level. Secrets are never logged.
+func WithSlog(logger *slog.Logger) Option {
+	return func(v2 *signer) {
+		v2.Slog = logger
 	}
+}
 
-	req.HTTPRequest.Header.Del("Authorization")
-	req.Error = v2.Sign()
+// WithAllowAnonymous leaves requests unsigned when the credentials are
+// anonymous, with no access key id, instead of failing with
+// ErrAnonymousCredentials
+func WithAllowAnonymous() Option {
+	return func(v2 *signer) {
+		v2.AllowAnonymous = true
+	}
+}
 
-	if req.Error != nil {
-		return
+// WithCredentialRefreshWindow refreshes the credentials before signing when
+// they expire within window of the signing time, so long lived clients
+// using temporary credentials don't sign with keys about to expire.
+// Credentials are refreshed early once per expiry, so keys that last no
+// longer than the window aren't fetched again for every request until
+// they expire. Expired credentials are always refreshed.
+func WithCredentialRefreshWindow(window time.Duration) Option {
+	refreshed := &refreshedExpiries{}
+	return func(v2 *signer) {
+		v2.RefreshWindow = window
+		v2.refreshed = refreshed
 	}
+}
 
-	req.HTTPRequest.Header.Add("Authorization", v2.Query.Get("Authorization"))
-	if req.HTTPRequest.Header.Get("Date") == "" {
-		req.HTTPRequest.Header.Set("Date", v2.Query.Get("Date"))
+// WithMaxPresignExpiry sets the longest presigned requests can be valid
+// for, DefaultMaxPresignExpiry if not set. Presigning for longer fails with
+// ErrExpiryTooLong. A negative max removes the limit.
+func WithMaxPresignExpiry(max time.Duration) Option {
+	return func(v2 *signer) {
+		v2.MaxPresignExpiry = max
 	}
 }
 
-// Sign the request
+// WithClock signs requests with the time returned by now instead of the
+// local clock, like WithClockSource
+func WithClock(now func() time.Time) Option {
+	return WithClockSource(ClockFunc(now))
+}
+
+// WithClockSource dates requests, and checks presign expiries, with the
+// time of clock instead of the local clock
+func WithClockSource(clock Clock) Option {
+	return func(v2 *signer) {
+		v2.Clock = clock
+	}
+}
+
+// WithReplaceDate dates requests with the signing time even when they
+// already have a date, so a request signed again, such as a retry, gets a
+// fresh one
+func WithReplaceDate() Option {
+	return func(v2 *signer) {
+		v2.ReplaceDate = true
+	}
+}
+
+// WithBody computes Content-MD5 from body, which must be the content of
+// the request, instead of the body of the request
+func WithBody(body io.ReadSeeker) Option {
+	return func(v2 *signer) {
+		v2.Body = body
+	}
+}
+
+// WithBucket signs requests for bucket, so the canonical resource of
+// virtual hosted requests is right whatever the endpoint host looks like
+func WithBucket(bucket string) Option {
+	return func(v2 *signer) {
+		v2.Bucket = bucket
+	}
+}
+
+// WithHost signs requests for host instead of the host they are sent to,
+// such as the host a load balancer presents to the backend when it
+// rewrites the Host header en route
+func WithHost(host string) Option {
+	return func(v2 *signer) {
+		v2.Host = host
+	}
+}
+
+// WithEndpointSuffix signs requests for a service whose endpoint host is
+// suffix, so the bucket of virtual host style requests is the part of the
+// host before the suffix, and requests to the suffix itself are path style
+func WithEndpointSuffix(suffix string) Option {
+	return func(v2 *signer) {
+		v2.EndpointSuffix = strings.ToLower(suffix)
+	}
+}
+
+// WithSubResources signs the query parameters named in names as
+// subresources, in addition to the ones S3 signs, for services that sign
+// their own, such as the ?append and ?position of Ceph RGW
+func WithSubResources(names ...string) Option {
+	return func(v2 *signer) {
+		v2.SubResources = names
+	}
+}
+
+// WithAmzDateHeader sets the signing time in the x-amz-date header instead
+// of the Date header, for clients (such as browsers) and proxies that
+// can't set or don't preserve Date.
+func WithAmzDateHeader() Option {
+	return func(v2 *signer) {
+		v2.UseAmzDate = true
+	}
+}
+
+// WithContentMD5 computes and sets the Content-MD5 header of PUT and POST
+// requests that don't have one before signing. The body must be seekable,
+// or the request must have GetBody set, otherwise signing fails with
+// ErrContentMD5Unavailable rather than consume a streaming body.
+func WithContentMD5() Option {
+	return func(v2 *signer) {
+		v2.ComputeContentMD5 = true
+	}
+}
+
+// WithSignatureMethod computes signatures with the HMAC algorithm of method
+// instead of HmacSHA1, for services that accept V2 style authentication
+// with another hash. Servers verify with WithVerifierSignatureMethod.
+func WithSignatureMethod(method SignatureMethod) Option {
+	return func(v2 *signer) {
+		v2.SignatureMethod = method
+	}
+}
+
+// WithAuthScheme labels the Authorization value with scheme instead of
+// "AWS", such as "GOOG1" for Google's legacy authentication, so the same
+// signing can target other V2 derived schemes. Servers verify with
+// WithVerifierAuthScheme.
+func WithAuthScheme(scheme string) Option {
+	return func(v2 *signer) {
+		v2.AuthScheme = scheme
+	}
+}
+
+// WithHeaderPrefixes signs the headers starting with any of prefixes as the
+// canonical headers, instead of the "x-amz" headers. Use "x-goog" for
+// Google Storage or "x-emc" for EMC Atmos/ECS. Servers verify with
+// WithVerifierHeaderPrefixes.
+func WithHeaderPrefixes(prefixes ...string) Option {
+	return func(v2 *signer) {
+		v2.HeaderPrefixes = prefixes
+	}
+}
+
+// WithHeaderAllowlist signs only the canonical headers named in names,
+// leaving the others out of the canonical amz headers, for requests sent
+// through proxies that strip or rewrite headers after signing. The server
+// must verify with the same allowlist, such as with
+// WithVerifierHeaderAllowlist.
+func WithHeaderAllowlist(names ...string) Option {
+	allowlist := lowerNames(names)
+	return func(v2 *signer) {
+		v2.HeaderAllowlist = allowlist
+	}
+}
+
+// lowerNames returns the header names in lower case
+func lowerNames(names []string) []string {
+	lower := make([]string, len(names))
+	for i, name := range names {
+		lower[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+	return lower
+}
+
+// Sign the request. Anonymous requests are left unsigned when
+// AllowAnonymous is set.
 func (v2 *signer) Sign() error {
-	credValue, err := v2.Credentials.Get()
+	m := v2.metrics()
+	m.SignAttempted()
+	start := time.Now()
+	err := v2.sign()
+	m.SignLatency(time.Since(start))
 	if err != nil {
+		m.SignFailed(err)
+	}
+	return err
+}
+
+func (v2 *signer) sign() error {
+	if err := checkRequest(v2.Request); err != nil {
+		return err
+	}
+	if err := v2.checkConflictingAuth(); err != nil {
+		return err
+	}
+	credValue, err := v2.getCredentials()
+	if err != nil || v2.anonymous {
+		return err
+	}
+
+	if v2.ComputeContentMD5 || requiresContentMD5(v2.Request) {
+		if err := v2.setContentMD5(); err != nil {
+			return err
+		}
+	}
+
+	// temporary credentials are only valid with their session token
+	if credValue.SessionToken != "" {
+		v2.Request.Header.Set("X-Amz-Security-Token", credValue.SessionToken)
+	}
+
+	// x-amz-date overrides Date, in which case it is signed as part of
+	// the canonical amz headers and the Date field is left empty
+	dateHeader := "Date"
+	if v2.UseAmzDate || v2.Request.Header.Get("X-Amz-Date") != "" {
+		dateHeader = "X-Amz-Date"
+	}
+
+	// an explicit signing time always wins, so a request that is signed
+	// again gets a fresh date
+	if !v2.Time.IsZero() {
+		v2.Request.Header.Set(dateHeader, v2.Time.UTC().Format(timeFormat))
+	} else if v2.ReplaceDate || v2.Request.Header.Get(dateHeader) == "" {
+		v2.Request.Header.Set(dateHeader, v2.now().Add(v2.ClockOffset).UTC().Format(timeFormat))
+	}
+
+	v2.buildStringToSign(dateField(v2.Request.Header))
+	if v2.signature, err = v2.signString(credValue.SecretAccessKey); err != nil {
 		return err
 	}
+	v2.authorization = v2.authScheme() + " " + credValue.AccessKeyID + ":" + v2.signature
 
-	// in case this is a retry, ensure no signature present
-	v2.Query.Del("Authorization")
+	v2.log(credValue)
+	// the request is signed at the time in its date header, which the
+	// caller may have set
+	signedAt, err := parseRequestTime(v2.Request.Header.Get(dateHeader))
+	if err != nil {
+		signedAt = v2.now()
+	}
+	v2.signed(credValue.AccessKeyID, signedAt.UTC(), time.Time{})
 
-	method := v2.Request.Method
-	md5 := v2.Request.Header.Get("Content-Md5")
-	contentType := v2.Request.Header.Get("Content-Type")
+	return nil
+}
 
-	if v2.Request.Header.Get("Date") == "" {
-		v2.Request.Header.Set("Date", time.Now().UTC().Format(timeFormat))
+// Presign computes the signature of a presigned request valid until
+// expires, and returns the query parameters that authenticate it
+func (v2 *signer) Presign(expires time.Time) (url.Values, error) {
+	m := v2.metrics()
+	m.SignAttempted()
+	start := time.Now()
+	query, err := v2.presign(expires)
+	m.SignLatency(time.Since(start))
+	if err != nil {
+		m.SignFailed(err)
+		return nil, err
+	}
+	if !v2.anonymous {
+		m.Presigned()
 	}
-	date := v2.Request.Header.Get("Date")
+	return query, nil
+}
+
+func (v2 *signer) presign(expires time.Time) (url.Values, error) {
+	if err := checkRequest(v2.Request); err != nil {
+		return nil, err
+	}
+	if err := v2.checkExpiry(expires); err != nil {
+		return nil, err
+	}
+	if err := v2.checkConflictingAuth(); err != nil {
+		return nil, err
+	}
+	return v2.signQuery(expires)
+}
+
+// signQuery computes the signature of the request valid until expires, and
+// returns the query parameters that authenticate it
+func (v2 *signer) signQuery(expires time.Time) (url.Values, error) {
+	credValue, err := v2.getCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if v2.anonymous {
+		return url.Values{}, nil
+	}
+
+	query := make(url.Values)
+	query.Set("AWSAccessKeyId", credValue.AccessKeyID)
+	query.Set("Expires", strconv.FormatInt(expires.Unix(), 10))
+
+	// the session token is signed as a canonical amz header, but sent in
+	// the query string so the URL works on its own
+	if credValue.SessionToken != "" {
+		v2.Request.Header.Set("X-Amz-Security-Token", credValue.SessionToken)
+		query.Set("x-amz-security-token", credValue.SessionToken)
+	}
+
+	v2.buildStringToSign(query.Get("Expires"))
+	if v2.signature, err = v2.signString(credValue.SecretAccessKey); err != nil {
+		return nil, err
+	}
+	query.Set("Signature", v2.signature)
+
+	v2.log(credValue)
+	v2.signed(credValue.AccessKeyID, v2.now(), expires)
+
+	return query, nil
+}
+
+// DefaultMaxPresignExpiry is the longest presigned requests can be valid
+// for, unless set by WithMaxPresignExpiry
+const DefaultMaxPresignExpiry = 7 * 24 * time.Hour
 
+// checkExpiry checks a presigned request expiring at expires is valid for
+// a positive duration no longer than the maximum
+func (v2 *signer) checkExpiry(expires time.Time) error {
+	expiry := expires.Sub(v2.now())
+	if expiry <= 0 {
+		return fmt.Errorf("%w: expires %s, %s ago", ErrInvalidExpiry,
+			expires.UTC().Format(time.RFC3339), -expiry.Round(time.Second))
+	}
+
+	max := v2.MaxPresignExpiry
+	if max == 0 {
+		max = DefaultMaxPresignExpiry
+	}
+	if max > 0 && expiry > max {
+		return fmt.Errorf("%w: valid for %s, longer than %s", ErrExpiryTooLong,
+			expiry.Round(time.Second), max)
+	}
+	return nil
+}
+
+// getCredentials gets the credentials, giving up when the context is done
+// so a hung credential provider does not block signing forever. Anonymous
+// credentials set anonymous when they are allowed, and are an error
+// otherwise.
+func (v2 *signer) getCredentials() (credentialsValue, error) {
+	v2.resolveCredentials()
+	var credValue credentialsValue
+	if v2.Provider != nil {
+		ctx := v2.context()
+		var err error
+		credValue, err = retrieveCredentials(ctx, v2.Provider)
+		if expiring, ok := v2.Provider.(ExpiringCredentialsProvider); ok && err == nil && v2.credentialsExpiring(expiring) {
+			expiring.Expire()
+			credValue, err = retrieveCredentials(ctx, v2.Provider)
+			if expiresAt, ok := expiring.ExpiresAt(); ok && err == nil && v2.refreshed != nil {
+				v2.refreshed.add(expiresAt, v2.now())
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return credentialsValue{}, fmt.Errorf("%w: %w", ErrNoCredentials, err)
+		}
+	}
+
+	if credValue.AccessKeyID == "" {
+		if !v2.AllowAnonymous {
+			return credentialsValue{}, fmt.Errorf("%w: %w", ErrNoCredentials, ErrAnonymousCredentials)
+		}
+		v2.anonymous = true
+	}
+	return credValue, nil
+}
+
+// credentialsExpiring reports whether the credentials of provider, which
+// are refreshed once they expire by the local clock, expire before the
+// signing time plus the refresh window, unless they were refreshed early
+// already. The signing time can be ahead of the local clock when it is
+// corrected for clock skew.
+func (v2 *signer) credentialsExpiring(provider ExpiringCredentialsProvider) bool {
+	expiresAt, ok := provider.ExpiresAt()
+	if !ok {
+		return false
+	}
+	signingTime := v2.Time
+	if signingTime.IsZero() {
+		signingTime = v2.now().Add(v2.ClockOffset)
+	}
+	if !expiresAt.After(signingTime) {
+		return true
+	}
+	return !expiresAt.After(signingTime.Add(v2.RefreshWindow)) &&
+		(v2.refreshed == nil || !v2.refreshed.contains(expiresAt))
+}
+
+// context returns the context of signing the request
+func (v2 *signer) context() context.Context {
+	if v2.Context != nil {
+		return v2.Context
+	}
+	return v2.Request.Context()
+}
+
+// now returns the current time
+func (v2 *signer) now() time.Time {
+	if v2.Clock != nil {
+		return v2.Clock.Now()
+	}
+	return SystemClock.Now()
+}
+
+// dateField returns the value of the Date field of the string to sign,
+// which is empty when x-amz-date is set since it overrides Date
+func dateField(header http.Header) string {
+	if header.Get("X-Amz-Date") != "" {
+		return ""
+	}
+	return header.Get("Date")
+}
+
+// buildStringToSign builds the canonical string for the V2 signature from
+// the request without modifying it. The date is the value of the Date
+// field, or the expiry time of presigned requests.
+func (v2 *signer) buildStringToSign(date string) {
 	v2.buildCanonicalizedResource()
 	v2.buildCanonicalizedAmzHeaders()
 
-	// build the canonical string for the V2 signature
-	v2.stringToSign = strings.Join([]string{
-		method,
-		md5,
-		contentType,
+	v2.stringToSign = StringToSign(
+		v2.Request.Method,
+		v2.Request.Header.Get("Content-Md5"),
+		v2.Request.Header.Get("Content-Type"),
 		date,
-	}, "\n")
-	v2.stringToSign += "\n"
-	v2.stringToSign += v2.canonicalAmzHeaders
-	v2.stringToSign += v2.canonicalResource
+		v2.canonicalAmzHeaders,
+		v2.canonicalResource)
+}
+
+// StringToSign returns the V2 string to sign of its parts. The date is the
+// Date header, "" when x-amz-date is set, or the Expires time of presigned
+// requests. The canonical headers end with a newline, as returned by
+// CanonicalAmzHeaders.
+func StringToSign(method, contentMD5, contentType, date, canonicalAmzHeaders, canonicalResource string) string {
+	return method + "\n" +
+		contentMD5 + "\n" +
+		contentType + "\n" +
+		date + "\n" +
+		canonicalAmzHeaders +
+		canonicalResource
+}
 
-	hash := hmac.New(sha1.New, []byte(credValue.SecretAccessKey))
+// signString returns the signature of the string to sign, computed by the
+// MACSigner if there is one, otherwise with the secret
+func (v2 *signer) signString(secret string) (string, error) {
+	if v2.MACSigner == nil {
+		return v2.computeSignature(secret), nil
+	}
+	sum, err := v2.MACSigner.Sum([]byte(v2.stringToSign))
+	if err != nil {
+		return "", fmt.Errorf("s3v2: computing signature: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// computeSignature returns the base64 encoded HMAC of the string to sign
+func (v2 *signer) computeSignature(secret string) string {
+	if v2.hmacPool != nil {
+		sum := v2.hmacPool.sum(v2.signatureMethod(), secret, v2.stringToSign)
+		return base64.StdEncoding.EncodeToString(sum)
+	}
+	hash := hmac.New(v2.signatureMethod().Hash, []byte(secret))
 	hash.Write([]byte(v2.stringToSign))
-	v2.signature = base64.StdEncoding.EncodeToString(hash.Sum(nil))
-	//v2.Query.Set("Signature", v2.signature)
-	v2.Query.Set("Authorization", "AWS "+credValue.AccessKeyID+":"+v2.signature)
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// signatureMethod returns the configured signature method or the default
+func (v2 *signer) signatureMethod() SignatureMethod {
+	if v2.SignatureMethod.Hash == nil {
+		return HmacSHA1
+	}
+	return v2.SignatureMethod
+}
 
-	if v2.Debug.Matches(aws.LogDebugWithSigning) {
-		v2.logSigningInfo()
+// authScheme returns the configured authorization scheme or the default
+func (v2 *signer) authScheme() string {
+	if v2.AuthScheme == "" {
+		return defaultAuthScheme
 	}
+	return v2.AuthScheme
+}
+
+// contentMD5SubResources are the subresources S3 requires Content-MD5 for
+var contentMD5SubResources = []string{"delete"}
 
+// requiresContentMD5 reports whether S3 rejects the request without a
+// Content-MD5, such as a multi-object delete, so it is set even when
+// ComputeContentMD5 is not
+func requiresContentMD5(r *http.Request) bool {
+	if r.Method != "PUT" && r.Method != "POST" || r.URL.RawQuery == "" {
+		return false
+	}
+	query := r.URL.Query()
+	for _, name := range contentMD5SubResources {
+		if _, ok := query[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// setContentMD5 sets the Content-MD5 header of PUT and POST requests from
+// the body, if the header is not already set. The body is only read when
+// it can be without consuming it, from Body, GetBody or a seekable body,
+// otherwise ErrContentMD5Unavailable is returned.
+func (v2 *signer) setContentMD5() error {
+	if v2.Request.Method != "PUT" && v2.Request.Method != "POST" {
+		return nil
+	}
+	if v2.Request.Header.Get("Content-Md5") != "" {
+		return nil
+	}
+
+	var sum string
+	var err error
+	switch body := v2.Request.Body; {
+	case v2.Body != nil:
+		sum, err = md5FromReadSeeker(v2.Body)
+	case body == nil || body == http.NoBody:
+		sum, err = md5FromReadSeeker(strings.NewReader(""))
+	case v2.Request.GetBody != nil:
+		var rc io.ReadCloser
+		rc, err = v2.Request.GetBody()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		sum, err = md5FromReader(rc)
+	default:
+		rs, ok := body.(io.ReadSeeker)
+		if !ok {
+			// streaming bodies are never read by signing
+			return fmt.Errorf("%w: set GetBody or use a seekable body", ErrContentMD5Unavailable)
+		}
+		sum, err = md5FromReadSeeker(rs)
+	}
+	if err != nil {
+		return err
+	}
+
+	v2.Request.Header.Set("Content-Md5", sum)
 	return nil
 }
 
+// md5FromReadSeeker returns the base64 encoded MD5 of the rest of body,
+// and restores the offset of body afterwards
+func md5FromReadSeeker(body io.ReadSeeker) (string, error) {
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	sum, err := md5FromReader(body)
+	if err != nil {
+		return "", err
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// md5FromReader returns the base64 encoded MD5 of everything read from r
+func md5FromReader(r io.Reader) (string, error) {
+	hash := md5.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
+}
+
 func (v2 *signer) buildCanonicalizedResource() {
-	// This is terrible, but host and path seem to never bet set,
-	// so we are always going back to the opaque to figure these out
-	// better way?  must be?
-	if v2.Request.Host == "" {
-		v2.Request.Host = strings.Split(v2.Request.URL.Opaque, "/")[2]
+	host := v2.host()
+	path := requestPath(v2.Request.URL)
+	if v2.KeyNormalization != KeyAsIs {
+		path = normalizeKey(path, v2.KeyNormalization)
 	}
-	if v2.Request.URL.Path == "" {
-		v2.Request.URL.Path = "/" + strings.Join(strings.Split(v2.Request.URL.Opaque, "/")[3:], "/")
+	if v2.PathEncoding == PathNormalize {
+		path = normalizePathEncoding(path)
+	}
+	if v2.EndpointTemplate != nil {
+		policy := v2.EndpointTemplate.BucketInResource
+		if policy == BucketInResourceAuto {
+			policy = v2.BucketInResource
+		}
+		if resource, ok := v2.EndpointTemplate.canonicalPath(host, path, policy); ok {
+			v2.canonicalResource = resource + canonicalSubResources(v2.Request.URL.RawQuery, v2.SubResources...)
+			return
+		}
 	}
 
-	if v2.PathStyle {
-		v2.canonicalResource = v2.Request.URL.Path
-	} else {
-		v2.canonicalResource = ""
-		// This feels fragile, find a better way
-		if strings.Count(v2.Request.Host, ".") == 3 {
-			v2.canonicalResource = "/" + strings.Split(v2.Request.Host, ".")[0]
-		}
-		v2.canonicalResource += v2.Request.URL.Path
-		if v2.canonicalResource == "" {
-			v2.canonicalResource = "/"
-		}
-	}
-
-	first := true
-	subResources := "acl,lifecycle,location,logging,notification,partNumber,policy,requestPayment,torrent,uploadId,uploads,versionId,versioning,versions,website"
-
-	// would be better to swap these, but it appears that we need
-	// to keep this in lexicographically sorted order
-	// so just loop looking for the subresources we care about
-	// in the correct order
-	// the resources section (if there are any) always start with ?
-	// after that they are separated by &
-	for _, sr := range strings.Split(subResources, ",") {
-		for _, reqSubResource := range strings.Split(v2.Request.URL.RawQuery, "&") {
-			if strings.HasPrefix(reqSubResource, sr) {
-				if first {
-					v2.canonicalResource += "?"
-					first = false
-				} else {
-					v2.canonicalResource += "&"
-				}
-				// ugh, multipart intiates with ?uploads=
-				// but we only sign with ?uploads
-				r := strings.Split(reqSubResource, "=")
-				if len(r) < 2 || r[1] == "" {
-					v2.canonicalResource += r[0]
-				} else {
-					v2.canonicalResource += reqSubResource
-				}
-				break
-			}
+	// the bucket of virtual host style requests follows the base path
+	basePath, hasBasePath := "", false
+	if v2.BasePath != "" {
+		path, hasBasePath = cutBasePath(path, v2.BasePath)
+		if hasBasePath && v2.BasePathMode == BasePathSigned {
+			basePath = v2.BasePath
 		}
 	}
+
+	bucket, pathStyle := v2.Bucket, v2.PathStyle
+	if bucket == "" && !pathStyle && v2.EndpointSuffix != "" {
+		bucket, pathStyle = bucketFromHost(host, v2.EndpointSuffix)
+	}
+	switch {
+	case v2.BucketInResource == BucketInResourceNever:
+		v2.canonicalResource = basePath + path + canonicalSubResources(v2.Request.URL.RawQuery, v2.SubResources...)
+	case v2.BucketInResource == BucketInResourceAlways && bucket != "" && !pathStyle && !isIPHost(host):
+		v2.canonicalResource = basePath + "/" + bucket + path +
+			canonicalSubResources(v2.Request.URL.RawQuery, v2.SubResources...)
+	default:
+		v2.canonicalResource = basePath + canonicalResource(v2.Request.URL, path, host, bucket,
+			pathStyle, v2.SubResources)
+	}
 }
 
-func (v2 *signer) buildCanonicalizedAmzHeaders() {
-	var headers []string
-	lowerCaseHeaders := make(url.Values)
-	for header := range v2.Request.Header {
-		lowerCaseHeader := strings.ToLower(strings.TrimSpace(header))
-		if strings.HasPrefix(lowerCaseHeader, "x-amz") && !stringInSlice(lowerCaseHeader, headers) {
-			for _, value := range v2.Request.Header[header] {
-				lowerCaseHeaders.Add(lowerCaseHeader, value)
-			}
-			headers = append(headers, lowerCaseHeader)
+// bucketFromHost returns the bucket of a request to host on the service
+// endpoint suffix, or reports the request is path style when it is sent to
+// the suffix itself. Hosts of other services have no bucket, so it is
+// guessed by CanonicalResource.
+func bucketFromHost(host, suffix string) (bucket string, pathStyle bool) {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == suffix {
+		return "", true
+	}
+	bucket, found := strings.CutSuffix(host, "."+suffix)
+	if !found {
+		return "", false
+	}
+	return bucket, false
+}
+
+// host returns the host the request is signed for
+func (v2 *signer) host() string {
+	if v2.Host != "" {
+		return v2.Host
+	}
+	return requestHost(v2.Request)
+}
+
+// CanonicalResource returns the canonical resource of a request to u sent
+// to host. The bucket, if known, tells virtual host style requests from
+// path style ones, otherwise it is guessed from the host. Path style
+// requests, and requests to IP address hosts, are signed with the path
+// as-is.
+func CanonicalResource(u *url.URL, host, bucket string, pathStyle bool) string {
+	return canonicalResource(u, requestPath(u), host, bucket, pathStyle, nil)
+}
+
+// canonicalResource returns the canonical resource like CanonicalResource
+// for the escaped path of u, also signing the extra subresources
+func canonicalResource(u *url.URL, path, host, bucket string, pathStyle bool, extra []string) string {
+
+	var resource string
+	switch {
+	// IP literal hosts can never carry a bucket name, so these endpoints
+	// (MinIO, Ceph, etc) are always path style
+	case pathStyle || isIPHost(host):
+		resource = path
+	case bucket != "":
+		// the SDK only moves the bucket into the host when it is DNS
+		// compatible, otherwise the request was left in path style
+		if hostCompatibleBucketName(u, bucket) && strings.HasPrefix(host, bucket+".") {
+			resource = "/" + bucket + path
+		} else {
+			resource = path
 		}
+	default:
+		// This feels fragile, find a better way
+		if strings.Count(host, ".") == 3 {
+			resource = "/" + host[:strings.IndexByte(host, '.')] + path
+		} else {
+			resource = path
+		}
+	}
+
+	if u.RawQuery == "" {
+		return resource
 	}
+	return resource + canonicalSubResources(u.RawQuery, extra...)
+}
 
-	sort.Strings(headers)
+// subResources are the query parameters that are part of the canonical
+// resource
+var subResources = map[string]bool{
+	"acl":            true,
+	"delete":         true,
+	"lifecycle":      true,
+	"location":       true,
+	"logging":        true,
+	"notification":   true,
+	"partNumber":     true,
+	"policy":         true,
+	"requestPayment": true,
+	"torrent":        true,
+	"uploadId":       true,
+	"uploads":        true,
+	"versionId":      true,
+	"versioning":     true,
+	"versions":       true,
+	"website":        true,
 
-	for i, header := range headers {
-		values := lowerCaseHeaders[header]
-		for _, value := range values {
-			strings.Replace(value, "\n", " ", -1)
+	// subresources added since the V2 signature was introduced
+	"accelerate":        true,
+	"analytics":         true,
+	"cors":              true,
+	"encryption":        true,
+	"inventory":         true,
+	"legal-hold":        true,
+	"metrics":           true,
+	"object-lock":       true,
+	"publicAccessBlock": true,
+	"replication":       true,
+	"restore":           true,
+	"retention":         true,
+	"tagging":           true,
+
+	// response header overrides of GET requests
+	"response-cache-control":       true,
+	"response-content-disposition": true,
+	"response-content-encoding":    true,
+	"response-content-language":    true,
+	"response-content-type":        true,
+	"response-expires":             true,
+}
+
+type subResource struct {
+	name  string
+	value string
+}
+
+// canonicalSubResources parses the raw query into the subresources that
+// are signed, those S3 signs and the extra ones, and returns them sorted
+// lexicographically by name in the form "?name&name=value", or "" if there
+// are none
+func canonicalSubResources(rawQuery string, extra ...string) string {
+	// requests rarely have more than a few subresources, so they fit in
+	// an array on the stack
+	var buf [8]subResource
+	srs := buf[:0]
+	size := 0
+	for rawQuery != "" {
+		var param string
+		param, rawQuery, _ = strings.Cut(rawQuery, "&")
+
+		// multipart initiates with ?uploads= but we only sign with
+		// ?uploads, so an empty value is the same as no value
+		name, value, hasValue := strings.Cut(param, "=")
+		name = decodeSubResourceValue(name)
+		if !subResources[name] && !slices.Contains(extra, name) {
+			continue
+		}
+		sr := subResource{name: name}
+		if hasValue {
+			// the canonical resource uses the decoded value
+			sr.value = decodeSubResourceValue(value)
 		}
-		headers[i] = header + ":" + strings.Join(values, ",")
+		srs = append(srs, sr)
+		size += len(sr.name) + len(sr.value) + 2
 	}
 
-	if len(headers) > 0 {
-		v2.canonicalAmzHeaders = strings.Join(headers, "\n") + "\n"
-	} else {
-		v2.canonicalAmzHeaders = ""
+	if len(srs) == 0 {
+		return ""
 	}
+
+	slices.SortStableFunc(srs, func(a, b subResource) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	var b strings.Builder
+	b.Grow(size)
+	for i, sr := range srs {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(sr.name)
+		if sr.value != "" {
+			b.WriteByte('=')
+			b.WriteString(sr.value)
+		}
+	}
+	return b.String()
 }
 
-func stringInSlice(str string, list []string) bool {
-	for _, v := range list {
-		if v == str {
+// isCanonicalHeader reports whether the lower case header starts with one
+// of prefixes, "x-amz" if there are none, so it is included in the
+// canonical headers
+func isCanonicalHeader(header string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return strings.HasPrefix(header, "x-amz")
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(header, strings.ToLower(prefix)) {
 			return true
 		}
 	}
 	return false
 }
 
-const logSignInfoMsg = `DEBUG: Request Signature:
----[ STRING TO SIGN ]--------------------------------
-%s
----[ SIGNATURE ]-------------------------------------
-%s
------------------------------------------------------`
+// decodeSubResourceValue returns the URL-decoded subresource value, or the
+// value as-is if it is not validly encoded
+func decodeSubResourceValue(value string) string {
+	decoded, err := url.QueryUnescape(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+func (v2 *signer) buildCanonicalizedAmzHeaders() {
+	v2.canonicalAmzHeaders = canonicalAmzHeaders(v2.Request.Header, v2.HeaderPrefixes,
+		v2.HeaderAllowlist)
+}
+
+// CanonicalAmzHeaders returns the canonical x-amz headers of a request with
+// header, each "name:value" line ending with a newline, or "" if it has
+// none
+func CanonicalAmzHeaders(header http.Header) string {
+	return canonicalAmzHeaders(header, nil, nil)
+}
+
+// amzHeader is a canonical header, by its lower case name and its name in
+// the request header
+type amzHeader struct {
+	lower string
+	name  string
+}
+
+// canonicalAmzHeaders returns the canonical headers of header starting
+// with any of prefixes, restricted to the allowlist if it is not nil
+func canonicalAmzHeaders(header http.Header, prefixes, allowlist []string) string {
+	var buf [16]amzHeader
+	headers := buf[:0]
+	for name := range header {
+		lower := strings.ToLower(strings.TrimSpace(name))
+		if isCanonicalHeader(lower, prefixes) &&
+			(allowlist == nil || slices.Contains(allowlist, lower)) {
+			headers = append(headers, amzHeader{lower: lower, name: name})
+		}
+	}
+
+	if len(headers) == 0 {
+		return ""
+	}
+
+	// headers set directly in the map can differ only in case, their
+	// values are merged in the order of the sorted names so signing is
+	// deterministic
+	slices.SortFunc(headers, func(a, b amzHeader) int {
+		if c := strings.Compare(a.lower, b.lower); c != 0 {
+			return c
+		}
+		return strings.Compare(a.name, b.name)
+	})
+
+	var b strings.Builder
+	for i, h := range headers {
+		if i > 0 && h.lower == headers[i-1].lower {
+			b.WriteByte(',')
+		} else {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(h.lower)
+			b.WriteByte(':')
+		}
+		for j, value := range header[h.name] {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(unfoldHeaderValue(value))
+		}
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// normalizeEscapedPath normalizes an escaped path to the form it takes on
+// the wire. Valid percent-encodings are kept as-is, any byte that is not
+// allowed in a path (such as space, '?' and '#' in a key) is escaped, and
+// a path that is empty or relative is sent from "/".
+func normalizeEscapedPath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	// most paths are already normalized and are returned as-is
+	first := -1
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '%' && i+2 < len(path) && isHex(path[i+1]) && isHex(path[i+2]) {
+			i += 2
+			continue
+		}
+		if shouldEscapePathByte(c) {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return path
+	}
+
+	var b strings.Builder
+	b.Grow(len(path) + 8)
+	b.WriteString(path[:first])
+	for i := first; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '%' && i+2 < len(path) && isHex(path[i+1]) && isHex(path[i+2]):
+			b.WriteString(path[i : i+3])
+			i += 2
+		case shouldEscapePathByte(c):
+			writeEscaped(&b, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// shouldEscapePathByte reports whether c must be percent-encoded in a path
+// segment (RFC 3986 section 3.3)
+func shouldEscapePathByte(c byte) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return false
+	}
+	switch c {
+	case '-', '.', '_', '~', '!', '$', '&', '\'', '(', ')', '*', '+', ',', ';', '=', ':', '@', '/':
+		return false
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// isIPHost reports whether the host (with or without a port) is an
+// IPv4 or IPv6 address literal
+func isIPHost(host string) bool {
+	// host names, the common case, can't be parsed as an address
+	if !strings.Contains(host, ":") && (host == "" || host[0] < '0' || host[0] > '9') {
+		return false
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	// strip any IPv6 zone identifier
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host) != nil
+}
 
-func (v2 *signer) logSigningInfo() {
-	msg := fmt.Sprintf(logSignInfoMsg, v2.stringToSign, v2.Query.Get("Authorization"))
-	v2.Logger.Log(msg)
+var (
+	reDomain    = regexp.MustCompile(`^[a-z0-9][a-z0-9\.\-]{1,61}[a-z0-9]$`)
+	reIPAddress = regexp.MustCompile(`^(\d+\.){3}\d+$`)
+)
+
+// dnsCompatibleBucketName returns true if the bucket name follows the S3
+// DNS naming rules and can be used as a virtual host label
+func dnsCompatibleBucketName(bucket string) bool {
+	return reDomain.MatchString(bucket) &&
+		!reIPAddress.MatchString(bucket) &&
+		!strings.Contains(bucket, "..")
+}
+
+// hostCompatibleBucketName returns true if the bucket can be expressed as
+// a virtual host. This mirrors the rules the SDK uses when it decides
+// whether to move the bucket from the path into the host.
+func hostCompatibleBucketName(u *url.URL, bucket string) bool {
+	// dots in the bucket will fail certificate validation for https
+	if u.Scheme == "https" && strings.Contains(bucket, ".") {
+		return false
+	}
+	return dnsCompatibleBucketName(bucket)
+}
+
+var reFoldingWhitespace = regexp.MustCompile(`[ \t]*(\r?\n[ \t]*)+`)
+
+// unfoldHeaderValue unfolds a header value that spans multiple lines (RFC
+// 2616 section 4.2) by replacing the folding whitespace, including the line
+// breaks, with a single space, and trims whitespace around the value
+func unfoldHeaderValue(value string) string {
+	if !strings.Contains(value, "\n") {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(reFoldingWhitespace.ReplaceAllString(value, " "))
+}
+
+// log logs the signed request to the logger, if set. The secret access key
+// and session token are redacted from everything logged.
+func (v2 *signer) log(credValue credentialsValue) {
+	if v2.Slog == nil {
+		return
+	}
+	r := newRedactor(credValue)
+	v2.Slog.LogAttrs(v2.context(), slog.LevelDebug, "signed request",
+		slog.String("method", v2.Request.Method),
+		slog.String("host", r.redact(v2.host())),
+		slog.String("canonical_resource", r.redact(v2.canonicalResource)),
+		slog.String("access_key_id", r.redact(credValue.AccessKeyID)),
+		slog.String("string_to_sign", r.redact(v2.stringToSign)),
+		slog.String("signature_method", v2.signatureMethod().Name),
+		slog.String("signature", v2.signature))
 }
@@ -0,0 +1,44 @@
+// Package s3v2otel records the V2 signing of requests on OpenTelemetry
+// trace spans
+package s3v2otel
+
+import (
+	"context"
+
+	"github.com/benmcclelland/s3v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EventName is the name of the span event recorded for signing
+const EventName = "s3v2.sign"
+
+// Attribute keys of the span event
+const (
+	SignatureVersionKey        = attribute.Key("s3.signature_version")
+	PathStyleKey               = attribute.Key("s3.path_style")
+	PresignedKey               = attribute.Key("s3.presigned")
+	BucketKey                  = attribute.Key("s3.bucket")
+	CanonicalResourceLengthKey = attribute.Key("s3.canonical_resource_length")
+)
+
+// Hook records a span event on the span of the request context when a
+// request is signed. Requests without a recording span are ignored.
+func Hook(ctx context.Context, info s3v2.SignInfo) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.AddEvent(EventName, trace.WithAttributes(
+		SignatureVersionKey.String(info.SignatureVersion),
+		PathStyleKey.Bool(info.PathStyle),
+		PresignedKey.Bool(info.Presigned),
+		BucketKey.String(info.Bucket),
+		CanonicalResourceLengthKey.Int(len(info.CanonicalResource)),
+	))
+}
+
+// WithTracing records the signing of requests on their trace spans
+func WithTracing() s3v2.Option {
+	return s3v2.WithSignHook(Hook)
+}
@@ -0,0 +1,112 @@
+// Package s3v2prom records the signing and verification of V2 signed
+// requests as Prometheus metrics
+package s3v2prom
+
+import (
+	"time"
+
+	"github.com/benmcclelland/s3v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements s3v2.Metrics with Prometheus counters and a histogram
+// of the signing latency, and s3v2.QueueMetrics with the depth, wait and
+// rejections of the queue of a signing service
+type Metrics struct {
+	signAttempts   prometheus.Counter
+	signFailures   prometheus.Counter
+	signLatency    prometheus.Histogram
+	presigns       prometheus.Counter
+	verifyFailures *prometheus.CounterVec
+	queueDepth     prometheus.Gauge
+	queueWait      prometheus.Histogram
+	queueRejected  prometheus.Counter
+}
+
+var (
+	_ s3v2.Metrics      = (*Metrics)(nil)
+	_ s3v2.QueueMetrics = (*Metrics)(nil)
+)
+
+// New returns Metrics registered with reg, named s3v2_*
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		signAttempts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "s3v2_sign_attempts_total",
+			Help: "Requests signed or presigned.",
+		}),
+		signFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "s3v2_sign_failures_total",
+			Help: "Requests that failed to be signed.",
+		}),
+		signLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "s3v2_sign_duration_seconds",
+			Help:    "Time taken to sign a request, including getting the credentials.",
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}),
+		presigns: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "s3v2_presigns_total",
+			Help: "Presigned requests issued.",
+		}),
+		verifyFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "s3v2_verify_failures_total",
+			Help: "Requests that failed verification, by S3 error code.",
+		}, []string{"reason"}),
+		queueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "s3v2_sign_queue_depth",
+			Help: "Requests waiting for a worker of the signing service.",
+		}),
+		queueWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "s3v2_sign_queue_wait_seconds",
+			Help:    "Time requests waited for a worker of the signing service.",
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}),
+		queueRejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "s3v2_sign_queue_rejected_total",
+			Help: "Requests done before there was room in the queue of the signing service.",
+		}),
+	}
+	reg.MustRegister(m.signAttempts, m.signFailures, m.signLatency, m.presigns, m.verifyFailures,
+		m.queueDepth, m.queueWait, m.queueRejected)
+	return m
+}
+
+// SignAttempted implements s3v2.Metrics
+func (m *Metrics) SignAttempted() {
+	m.signAttempts.Inc()
+}
+
+// SignFailed implements s3v2.Metrics
+func (m *Metrics) SignFailed(err error) {
+	m.signFailures.Inc()
+}
+
+// SignLatency implements s3v2.Metrics
+func (m *Metrics) SignLatency(d time.Duration) {
+	m.signLatency.Observe(d.Seconds())
+}
+
+// Presigned implements s3v2.Metrics
+func (m *Metrics) Presigned() {
+	m.presigns.Inc()
+}
+
+// VerifyFailed implements s3v2.Metrics
+func (m *Metrics) VerifyFailed(reason string) {
+	m.verifyFailures.WithLabelValues(reason).Inc()
+}
+
+// QueueDepth implements s3v2.QueueMetrics
+func (m *Metrics) QueueDepth(n int) {
+	m.queueDepth.Set(float64(n))
+}
+
+// QueueWait implements s3v2.QueueMetrics
+func (m *Metrics) QueueWait(d time.Duration) {
+	m.queueWait.Observe(d.Seconds())
+}
+
+// QueueRejected implements s3v2.QueueMetrics
+func (m *Metrics) QueueRejected() {
+	m.queueRejected.Inc()
+}
@@ -0,0 +1,162 @@
+package s3v2test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/benmcclelland/s3v2"
+)
+
+// UpdateGoldenEnv is the environment variable that, when set, makes Golden
+// record the golden file instead of checking it:
+//
+//	S3V2_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "S3V2_UPDATE_GOLDEN"
+
+// GoldenCase is the shape of a signed request and the values signing it
+// produced. The header includes the date signing set, so signing the shape
+// again is deterministic.
+type GoldenCase struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	StringToSign string      `json:"stringToSign"`
+	Signature    string      `json:"signature"`
+}
+
+// Golden records the signatures signer computes for reqs to the golden file
+// at path when UpdateGoldenEnv is set, and otherwise checks that signer
+// still computes the recorded signatures, failing t for every case that
+// changed. Committing the golden file catches changes of canonicalization
+// across versions of s3v2 that would break servers silently.
+func Golden(t testing.TB, path string, signer *s3v2.Signer, reqs []*http.Request) {
+	t.Helper()
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := RecordGolden(path, signer, reqs); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if err := ReplayGolden(path, signer); err != nil {
+		t.Error(err)
+	}
+}
+
+// RecordGolden signs copies of reqs with signer and writes their shapes,
+// strings to sign and signatures to the golden file at path. The bodies of
+// reqs must be readable again with GetBody.
+func RecordGolden(path string, signer *s3v2.Signer, reqs []*http.Request) error {
+	cases := make([]GoldenCase, 0, len(reqs))
+	for _, req := range reqs {
+		c, err := recordCase(signer, req)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", req.Method, req.URL, err)
+		}
+		cases = append(cases, c)
+	}
+
+	b, err := json.MarshalIndent(cases, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func recordCase(signer *s3v2.Signer, req *http.Request) (GoldenCase, error) {
+	var body string
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return GoldenCase{}, err
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return GoldenCase{}, err
+		}
+		body = string(b)
+	}
+
+	signed, err := signer.SignCopy(req)
+	if err != nil {
+		return GoldenCase{}, err
+	}
+	result, err := signer.Build(signed)
+	if err != nil {
+		return GoldenCase{}, err
+	}
+	header := signed.Header.Clone()
+	header.Del("Authorization")
+	return GoldenCase{
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		Header:       header,
+		Body:         body,
+		StringToSign: result.StringToSign,
+		Signature:    result.Signature,
+	}, nil
+}
+
+// ReplayGolden signs the request shapes of the golden file at path with
+// signer, and returns an error describing every case whose string to sign
+// or signature differs from the recorded one
+func ReplayGolden(path string, signer *s3v2.Signer) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cases []GoldenCase
+	if err := json.Unmarshal(b, &cases); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var errs []error
+	for _, c := range cases {
+		if err := c.check(signer); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", c.Method, c.URL, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Request returns a new unsigned request of the shape of the case
+func (c GoldenCase) Request() (*http.Request, error) {
+	var body io.Reader
+	if c.Body != "" {
+		body = strings.NewReader(c.Body)
+	}
+	req, err := http.NewRequest(c.Method, c.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = c.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	return req, nil
+}
+
+func (c GoldenCase) check(signer *s3v2.Signer) error {
+	req, err := c.Request()
+	if err != nil {
+		return err
+	}
+	result, err := signer.Build(req)
+	if err != nil {
+		return err
+	}
+	if result.StringToSign != c.StringToSign {
+		return fmt.Errorf("string to sign changed: got %q, golden %q", result.StringToSign, c.StringToSign)
+	}
+	if result.Signature != c.Signature {
+		return fmt.Errorf("signature changed: got %q, golden %q", result.Signature, c.Signature)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package s3v2test
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/benmcclelland/s3v2"
+	"github.com/benmcclelland/s3v2/testvectors"
+	"github.com/stretchr/testify/assert"
+)
+
+func goldenSigner(secret string) *s3v2.Signer {
+	return s3v2.NewSigner(s3v2.StaticCredentials{
+		AccessKeyID:     testvectors.AccessKeyID,
+		SecretAccessKey: secret,
+	})
+}
+
+func TestGolden(t *testing.T) {
+	var reqs []*http.Request
+	for _, v := range testvectors.Header {
+		reqs = append(reqs, v.Request())
+	}
+	Golden(t, filepath.Join("testdata", "golden.json"), goldenSigner(testvectors.SecretAccessKey), reqs)
+}
+
+func TestReplayGolden(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "golden.json")
+	req, _ := http.NewRequest("GET", "https://johnsmith.s3.amazonaws.com/photos/puppy.jpg", nil)
+	assert.NoError(RecordGolden(path, goldenSigner(testvectors.SecretAccessKey), []*http.Request{req}))
+	assert.NoError(ReplayGolden(path, goldenSigner(testvectors.SecretAccessKey)))
+
+	err := ReplayGolden(path, goldenSigner("other"))
+	assert.ErrorContains(err, "GET https://johnsmith.s3.amazonaws.com/photos/puppy.jpg: signature changed")
+}
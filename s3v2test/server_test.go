@@ -0,0 +1,96 @@
+package s3v2test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/benmcclelland/s3v2/awsv1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := NewServer()
+	defer srv.Close()
+	srv.Handle("GET", "/bucket/key", Response{
+		Header: http.Header{"Content-Type": {"text/plain"}},
+		Body:   []byte("hello"),
+	})
+
+	svc := awsv1.NewS3Client(srv.Config())
+	out, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("key"),
+	})
+	assert.NoError(err)
+	body, _ := io.ReadAll(out.Body)
+	assert.Equal("hello", string(body))
+	assert.Equal("text/plain", aws.StringValue(out.ContentType))
+
+	_, err = svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("other"),
+		Body:   strings.NewReader("data"),
+	})
+	if assert.Error(err) {
+		assert.Equal(http.StatusNotFound, err.(awserr.RequestFailure).StatusCode())
+	}
+
+	requests := srv.Requests()
+	assert.Len(requests, 2)
+	assert.Equal("/bucket/other", requests[1].URL.Path)
+	body, _ = io.ReadAll(requests[1].Body)
+	assert.Equal("data", string(body))
+}
+
+func TestServerRejects(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := NewServer()
+	defer srv.Close()
+	srv.Handle("GET", "/bucket/key", Response{})
+
+	cfg := srv.Config()
+	cfg.Credentials = credentials.NewStaticCredentials(AccessKeyID, "wrong", "")
+	_, err := awsv1.NewS3Client(cfg).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("key"),
+	})
+	if assert.Error(err) {
+		assert.Equal("SignatureDoesNotMatch", err.(awserr.Error).Code())
+	}
+
+	resp, err := http.Get(srv.URL + "/bucket/key")
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+	assert.Empty(srv.Requests())
+}
+
+func TestServerPresigned(t *testing.T) {
+	assert := assert.New(t)
+
+	srv := NewServer()
+	defer srv.Close()
+	srv.Handle("GET", "/bucket/key", Response{Body: []byte("hello")})
+
+	req, _ := http.NewRequest("GET", srv.URL+"/bucket/key", nil)
+	signer := awsv1.NewSigner(srv.Credentials())
+	u, err := signer.Presign(req, time.Now().Add(time.Minute))
+	assert.NoError(err)
+
+	resp, err := http.Get(u.String())
+	assert.NoError(err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("hello", string(body))
+}
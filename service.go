@@ -0,0 +1,161 @@
+package s3v2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrServiceClosed is returned by SignAsync after the SigningService is
+// closed
+var ErrServiceClosed = errors.New("s3v2: signing service is closed")
+
+// QueueMetrics records the backpressure of a SigningService, such as in
+// Prometheus (see the s3v2prom package). Methods are called concurrently.
+type QueueMetrics interface {
+	// QueueDepth is called with the number of requests waiting for a
+	// worker whenever it changes
+	QueueDepth(n int)
+	// QueueWait is called with the time a request waited for a worker
+	QueueWait(d time.Duration)
+	// QueueRejected is called when a request is done before there is
+	// room for it in the queue
+	QueueRejected()
+}
+
+type nopQueueMetrics struct{}
+
+func (nopQueueMetrics) QueueDepth(int)          {}
+func (nopQueueMetrics) QueueWait(time.Duration) {}
+func (nopQueueMetrics) QueueRejected()          {}
+
+// SigningService signs requests asynchronously on a bounded pool of
+// workers, so proxies signing on the hot path of every request are
+// isolated from latency spikes of the credential provider: a slow provider
+// fills the queue instead of piling up goroutines. A SigningService is safe
+// for concurrent use.
+type SigningService struct {
+	signer  *Signer
+	queue   chan signJob
+	metrics QueueMetrics
+
+	// done is closed by Close, and senders counts the SignAsync calls
+	// that may still send to the queue
+	mu      sync.Mutex
+	closed  bool
+	done    chan struct{}
+	senders sync.WaitGroup
+	wg      sync.WaitGroup
+}
+
+type signJob struct {
+	req      *http.Request
+	enqueued time.Time
+	done     chan<- error
+}
+
+// ServiceOption configures a SigningService
+type ServiceOption func(*SigningService)
+
+// WithQueueMetrics records the backpressure of the service in m
+func WithQueueMetrics(m QueueMetrics) ServiceOption {
+	return func(s *SigningService) {
+		if m != nil {
+			s.metrics = m
+		}
+	}
+}
+
+// NewSigningService returns a SigningService signing requests with signer
+// on workers goroutines, with room for queueSize requests waiting for a
+// worker. Close stops the workers. There must be at least one worker.
+func NewSigningService(signer *Signer, workers, queueSize int, opts ...ServiceOption) (*SigningService, error) {
+	if workers <= 0 {
+		return nil, fmt.Errorf("s3v2: signing service needs at least one worker, not %d", workers)
+	}
+	if queueSize < 0 {
+		return nil, fmt.Errorf("s3v2: signing service queue size %d is negative", queueSize)
+	}
+	s := &SigningService{
+		signer:  signer,
+		queue:   make(chan signJob, queueSize),
+		metrics: nopQueueMetrics{},
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s, nil
+}
+
+// SignAsync queues the request to be signed in place, and returns a
+// channel receiving the error of signing it, nil once it is signed. The
+// request must not be used until then. When the queue is full SignAsync
+// blocks until there is room, or the context of the request is done, in
+// which case the channel receives the error of the context, or the
+// service is closed.
+func (s *SigningService) SignAsync(req *http.Request) <-chan error {
+	done := make(chan error, 1)
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		done <- ErrServiceClosed
+		return done
+	}
+	s.senders.Add(1)
+	s.mu.Unlock()
+	defer s.senders.Done()
+
+	select {
+	case s.queue <- signJob{req: req, enqueued: time.Now(), done: done}:
+		s.metrics.QueueDepth(len(s.queue))
+	case <-req.Context().Done():
+		s.metrics.QueueRejected()
+		done <- req.Context().Err()
+	case <-s.done:
+		done <- ErrServiceClosed
+	}
+	return done
+}
+
+// Close stops accepting requests and waits for the workers to sign the
+// requests already queued. Requests waiting for room in the queue get
+// ErrServiceClosed.
+func (s *SigningService) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		s.wg.Wait()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.mu.Unlock()
+
+	// no request is sent to the queue once the senders are done
+	s.senders.Wait()
+	close(s.queue)
+	s.wg.Wait()
+}
+
+func (s *SigningService) work() {
+	defer s.wg.Done()
+	for job := range s.queue {
+		s.metrics.QueueDepth(len(s.queue))
+		s.metrics.QueueWait(time.Since(job.enqueued))
+		if err := job.req.Context().Err(); err != nil {
+			job.done <- err
+			continue
+		}
+		_, err := s.signer.Sign(job.req)
+		job.done <- err
+	}
+}
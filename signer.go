@@ -0,0 +1,182 @@
+package s3v2
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Signer signs standalone http requests, that were not built by an SDK
+// service client, with the V2 signature. A Signer is safe for concurrent
+// use: every request is signed by its own state, and the Signer itself is
+// never modified after NewSigner.
+//
+// Signing never reads a streaming body, so requests of any size are signed
+// without buffering them. Only computing Content-MD5 reads the body, from
+// GetBody or a seekable body whose offset is restored afterwards.
+type Signer struct {
+	provider CredentialsProvider
+	opts     []Option
+}
+
+// NewSigner returns a Signer that signs requests with the keys of
+// provider, configured by opts. Requests are anonymous if provider is nil.
+func NewSigner(provider CredentialsProvider, opts ...Option) *Signer {
+	// copy the options so the caller reusing its slice can't change them
+	return &Signer{provider: provider, opts: append([]Option(nil), opts...)}
+}
+
+// SigningResult are the values computed to sign a request, for callers
+// applying the signature themselves, such as to headers of their own
+// request type, or logging it. The result of an anonymous request is
+// empty.
+type SigningResult struct {
+	CanonicalResource   string
+	CanonicalAmzHeaders string
+	StringToSign        string
+	Signature           string
+	Authorization       string
+	// Date is the value of the Date (or x-amz-date) header signing would
+	// set, if it is not already set on the request
+	Date string
+	// DateHeader is the header Date is sent in, Date or X-Amz-Date
+	DateHeader string
+	// Query are the parameters authenticating requests signed with
+	// OutputQuery, which have no Authorization
+	Query url.Values
+}
+
+// Apply sets the Authorization header of req, and its date header if it
+// has none, to those of the result, or adds the query parameters of
+// results of OutputQuery to its URL. Applying the result of Build signs the
+// request it was built for.
+func (r SigningResult) Apply(req *http.Request) {
+	if len(r.Query) > 0 {
+		rawQuery := stripQueryParams(req.URL.RawQuery, presignQueryParams)
+		if rawQuery != "" {
+			rawQuery += "&"
+		}
+		req.URL.RawQuery = rawQuery + r.Query.Encode()
+		return
+	}
+	if r.Authorization == "" {
+		return
+	}
+	if req.Header.Get(r.DateHeader) == "" {
+		req.Header.Set(r.DateHeader, r.Date)
+	}
+	req.Header.Set("Authorization", r.Authorization)
+}
+
+// Sign signs the request in place, setting its Authorization header and,
+// when it has none, its Date (or x-amz-date) header, or the query
+// parameters of WithOutput(OutputQuery). It returns the values
+// computed to sign it. Getting the credentials is bounded by the request
+// context.
+func (s *Signer) Sign(req *http.Request) (SigningResult, error) {
+	return s.SignWithContext(req.Context(), req)
+}
+
+// SignWithContext signs the request like Sign, but gives up getting the
+// credentials, such as from a slow or hung instance metadata service, when
+// ctx is done. The error is then ctx.Err().
+func (s *Signer) SignWithContext(ctx context.Context, req *http.Request) (SigningResult, error) {
+	v2 := s.newSigner(req)
+	v2.Context = ctx
+	result, err := v2.signResult()
+	if err != nil {
+		return SigningResult{}, err
+	}
+	result.Apply(req)
+	return result, nil
+}
+
+// SignCopy signs a copy of the request and returns it, leaving the request
+// untouched, so requests shared with other goroutines can be signed. The
+// copy gets its own body from GetBody when the request has one, otherwise
+// the body is shared.
+func (s *Signer) SignCopy(req *http.Request) (*http.Request, error) {
+	signed := req.Clone(req.Context())
+	if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		signed.Body = body
+	}
+	if _, err := s.Sign(signed); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// Build computes the values signing the request would produce without
+// modifying the request or its headers. Reading the body, to compute
+// Content-MD5, restores it afterwards.
+func (s *Signer) Build(req *http.Request) (SigningResult, error) {
+	v2 := s.newSigner(req.Clone(req.Context()))
+	return v2.signResult()
+}
+
+// Presign returns the URL of req authenticated by the V2 signature in the
+// query string until expires, for handing to clients without credentials.
+// Signed headers of req, such as Content-Type or x-amz-* headers, must be
+// sent with the URL. The request is not modified.
+func (s *Signer) Presign(req *http.Request, expires time.Time) (*url.URL, error) {
+	v2 := s.newSigner(req.Clone(req.Context()))
+	query, err := v2.Presign(expires)
+	if err != nil {
+		return nil, err
+	}
+
+	u := *v2.Request.URL
+	if len(query) == 0 {
+		// anonymous requests are not presigned
+		return &u, nil
+	}
+	if u.RawQuery == "" {
+		u.RawQuery = query.Encode()
+	} else {
+		u.RawQuery += "&" + query.Encode()
+	}
+	return &u, nil
+}
+
+// Now returns the current time of the clock the options of the Signer
+// sign with, without the clock offset
+func (s *Signer) Now() time.Time {
+	v2 := s.newSigner(nil)
+	return v2.now()
+}
+
+// newSigner returns the signer for a single request
+func (s *Signer) newSigner(req *http.Request) signer {
+	v2 := signer{
+		Request:  req,
+		Provider: s.provider,
+	}
+	for _, opt := range s.opts {
+		opt(&v2)
+	}
+	return v2
+}
+
+// result returns the values computed by Sign
+func (v2 *signer) result() SigningResult {
+	dateHeader := "X-Amz-Date"
+	date := v2.Request.Header.Get(dateHeader)
+	if date == "" {
+		dateHeader = "Date"
+		date = v2.Request.Header.Get(dateHeader)
+	}
+	return SigningResult{
+		CanonicalResource:   v2.canonicalResource,
+		CanonicalAmzHeaders: v2.canonicalAmzHeaders,
+		StringToSign:        v2.stringToSign,
+		Signature:           v2.signature,
+		Authorization:       v2.authorization,
+		Date:                date,
+		DateHeader:          dateHeader,
+	}
+}
@@ -0,0 +1,43 @@
+package s3v2
+
+import "net/http"
+
+// Transport returns a RoundTripper that signs the requests it sends with
+// next, or http.DefaultTransport if it is nil, for http.Clients that sign
+// every request. CORS preflight requests are sent unsigned, since browsers
+// send them without credentials and servers answer them unauthenticated.
+func (s *Signer) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return signingTransport{signer: s, next: next}
+}
+
+type signingTransport struct {
+	signer *Signer
+	next   http.RoundTripper
+}
+
+func (t signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if IsPreflight(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the request
+	signed := req.Clone(req.Context())
+	if _, err := t.signer.Sign(signed); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.next.RoundTrip(signed)
+}
+
+// IsPreflight reports whether the request is a CORS preflight, an OPTIONS
+// request with the Origin and Access-Control-Request-Method of the actual
+// request
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
@@ -0,0 +1,107 @@
+package s3v2
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// URLError is returned when the URL of a request can't be signed, such as
+// when it has no host or is not an http URL. Err is ErrMissingHost or
+// ErrUnsupportedURL.
+type URLError struct {
+	URL    string
+	Reason string
+	Err    error
+}
+
+func (e *URLError) Error() string {
+	msg := e.Err.Error()
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return fmt.Sprintf("%s (URL %q)", msg, e.URL)
+}
+
+func (e *URLError) Unwrap() error {
+	return e.Err
+}
+
+// checkRequest returns an error if the URL of the request can't be signed
+func checkRequest(r *http.Request) error {
+	_, _, err := normalizeURL(r)
+	return err
+}
+
+// normalizeURL returns the host and escaped path of the request as they
+// are sent on the wire. The host is taken from r.Host, the URL, or the
+// opaque form "//host/path" older SDK versions set, in that order. The
+// path keeps its RawPath or opaque encoding, and relative or empty paths
+// are sent from "/". URLs that can't be sent to a host return a *URLError.
+func normalizeURL(r *http.Request) (host, path string, err error) {
+	u := r.URL
+	if u == nil {
+		return "", "", &URLError{Err: ErrUnsupportedURL, Reason: "request has no URL"}
+	}
+
+	host = r.Host
+	if host == "" {
+		host = u.Host
+	}
+	switch {
+	case strings.HasPrefix(u.Opaque, "//"):
+		var opaqueHost string
+		opaqueHost, path = splitOpaque(u.Opaque)
+		if host == "" {
+			host = opaqueHost
+		}
+	case u.Opaque != "" && !strings.HasPrefix(u.Opaque, "/"):
+		return "", "", &URLError{URL: u.String(), Err: ErrUnsupportedURL,
+			Reason: fmt.Sprintf("opaque %q is not a path", u.Opaque)}
+	case u.Opaque != "":
+		path = u.Opaque
+	default:
+		path = u.EscapedPath()
+	}
+
+	if host == "" {
+		return "", "", &URLError{URL: u.String(), Err: ErrMissingHost}
+	}
+	return host, normalizeEscapedPath(path), nil
+}
+
+// splitOpaque splits an opaque URL of the form "//host/path" into its host
+// and escaped path, which is empty if there is none
+func splitOpaque(opaque string) (host, path string) {
+	host, path, found := strings.Cut(strings.TrimPrefix(opaque, "//"), "/")
+	if found {
+		path = "/" + path
+	}
+	return host, path
+}
+
+// requestHost returns the host the request is sent to, or "" if it has none
+func requestHost(r *http.Request) string {
+	host, _, _ := normalizeURL(r)
+	return host
+}
+
+// requestPath returns the path of a request to u as it is sent on the wire,
+// keeping the original percent-encoding when there is one (RawPath or an
+// opaque path), so the canonical resource matches what the server sees
+func requestPath(u *url.URL) string {
+	if u == nil {
+		return "/"
+	}
+	var path string
+	switch {
+	case strings.HasPrefix(u.Opaque, "//"):
+		_, path = splitOpaque(u.Opaque)
+	case u.Opaque != "":
+		path = u.Opaque
+	default:
+		path = u.EscapedPath()
+	}
+	return normalizeEscapedPath(path)
+}
@@ -0,0 +1,85 @@
+package s3v2
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		host string
+		path string
+	}{
+		{"url", &http.Request{URL: &url.URL{Scheme: "https", Host: "s3.amazonaws.com", Path: "/johnsmith/a b"}},
+			"s3.amazonaws.com", "/johnsmith/a%20b"},
+		{"raw path", &http.Request{URL: &url.URL{Host: "s3.amazonaws.com", Path: "/johnsmith/a/b", RawPath: "/johnsmith/a%2Fb"}},
+			"s3.amazonaws.com", "/johnsmith/a%2Fb"},
+		{"request host wins", &http.Request{Host: "johnsmith.s3.amazonaws.com", URL: &url.URL{Host: "10.0.0.5", Path: "/"}},
+			"johnsmith.s3.amazonaws.com", "/"},
+		{"server request", &http.Request{Host: "s3.amazonaws.com", URL: &url.URL{Path: "/johnsmith"}},
+			"s3.amazonaws.com", "/johnsmith"},
+		{"relative path", &http.Request{Host: "s3.amazonaws.com", URL: &url.URL{Path: "johnsmith/key"}},
+			"s3.amazonaws.com", "/johnsmith/key"},
+		{"opaque", &http.Request{URL: &url.URL{Scheme: "https", Opaque: "//johnsmith.s3.amazonaws.com/a%20b"}},
+			"johnsmith.s3.amazonaws.com", "/a%20b"},
+		{"opaque without path", &http.Request{URL: &url.URL{Scheme: "https", Opaque: "//johnsmith.s3.amazonaws.com"}},
+			"johnsmith.s3.amazonaws.com", "/"},
+		{"opaque path", &http.Request{URL: &url.URL{Host: "s3.amazonaws.com", Opaque: "/johnsmith/a%2Fb"}},
+			"s3.amazonaws.com", "/johnsmith/a%2Fb"},
+	}
+	for _, test := range tests {
+		host, path, err := normalizeURL(test.req)
+		assert.NoError(err, test.name)
+		assert.Equal(test.host, host, test.name)
+		assert.Equal(test.path, path, test.name)
+	}
+}
+
+func TestNormalizeURLErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		err  error
+	}{
+		{"no url", &http.Request{}, ErrUnsupportedURL},
+		{"no host", &http.Request{URL: &url.URL{Path: "/johnsmith"}}, ErrMissingHost},
+		{"empty opaque host", &http.Request{URL: &url.URL{Scheme: "https", Opaque: "//"}}, ErrMissingHost},
+		{"opaque path without host", &http.Request{URL: &url.URL{Opaque: "/johnsmith"}}, ErrMissingHost},
+		{"not a path", &http.Request{URL: &url.URL{Scheme: "mailto", Opaque: "someone@example.com"}}, ErrUnsupportedURL},
+	}
+	for _, test := range tests {
+		_, _, err := normalizeURL(test.req)
+		assert.ErrorIs(err, test.err, test.name)
+		var uerr *URLError
+		assert.True(errors.As(err, &uerr), test.name)
+	}
+
+	_, _, err := normalizeURL(&http.Request{URL: &url.URL{Scheme: "mailto", Opaque: "someone@example.com"}})
+	assert.EqualError(err, `s3v2: unsupported URL: opaque "someone@example.com" is not a path (URL "mailto:someone@example.com")`)
+}
+
+func TestSignMalformedURL(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, req := range []*http.Request{
+		{Method: "GET", Header: make(http.Header)},
+		{Method: "GET", URL: &url.URL{Opaque: "//"}, Header: make(http.Header)},
+		{Method: "GET", URL: &url.URL{Opaque: "//host"}, Header: make(http.Header)},
+	} {
+		assert.NotPanics(func() {
+			newTestSigner().Sign(req)
+			newTestSigner().Build(req)
+		})
+	}
+	assert.Equal("/", requestPath(nil))
+}
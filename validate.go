@@ -0,0 +1,71 @@
+package s3v2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Validate checks the request can be signed by s, and reports every
+// problem V2 signing can't represent before it is signed and sent, such as
+// a request without a method, authentication already present that signing
+// would not replace, or a body Content-MD5 can't be computed from when the
+// request needs one. The errors wrap ErrMissingMethod, ErrConflictingAuth,
+// ErrContentMD5Unavailable or the *URLError of the URL.
+func (s *Signer) Validate(req *http.Request) error {
+	var errs []error
+	if req.Method == "" {
+		errs = append(errs, ErrMissingMethod)
+	}
+	if err := checkRequest(req); err != nil {
+		errs = append(errs, err)
+	}
+	if req.URL == nil {
+		return errors.Join(errs...)
+	}
+
+	v2 := s.newSigner(req)
+	if conflict := v2.conflictingAuth(); conflict != "" && v2.ConflictPolicy != ConflictStrip {
+		errs = append(errs, &ConflictingAuthError{Reason: conflict})
+	}
+	if (v2.ComputeContentMD5 || requiresContentMD5(req)) &&
+		(req.Method == "PUT" || req.Method == "POST") &&
+		req.Header.Get("Content-Md5") == "" && !v2.canReadBody() {
+		errs = append(errs, fmt.Errorf("%w: set GetBody or use a seekable body", ErrContentMD5Unavailable))
+	}
+	return errors.Join(errs...)
+}
+
+// conflictingAuth describes authentication of the request that signing
+// would not replace, or returns "" if there is none
+func (v2 *signer) conflictingAuth() string {
+	req := v2.Request
+	if auth := req.Header.Get("Authorization"); auth != "" && !strings.HasPrefix(auth, v2.authScheme()+" ") {
+		name, _, _ := strings.Cut(auth, " ")
+		return fmt.Sprintf("Authorization header uses %q", name)
+	}
+	query := req.URL.Query()
+	for _, name := range []string{"X-Amz-Signature", "X-Amz-Credential", "X-Amz-Algorithm"} {
+		if query.Has(name) {
+			return "query has SigV4 parameter " + name
+		}
+	}
+	// signing to the query replaces the parameters of an earlier signing
+	if query.Has("Signature") && v2.Output != OutputQuery {
+		return "query is already presigned"
+	}
+	return ""
+}
+
+// canReadBody reports whether the body can be read, to compute its
+// Content-MD5, without consuming it
+func (v2 *signer) canReadBody() bool {
+	body := v2.Request.Body
+	if v2.Body != nil || body == nil || body == http.NoBody || v2.Request.GetBody != nil {
+		return true
+	}
+	_, ok := body.(io.ReadSeeker)
+	return ok
+}
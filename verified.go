@@ -0,0 +1,85 @@
+package s3v2
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// VerifiedRequest describes what a verified request was signed for, from
+// its canonical resource and headers, so servers can authorize the
+// identity that signed it without parsing the request again
+type VerifiedRequest struct {
+	AccessKeyID string
+	Tenant      string
+	Method      string
+	// Bucket and Key are those of the canonical resource, unescaped. The
+	// bucket is "" for requests that list buckets, and for requests
+	// verified with BucketInResourceNever, whose resource has no bucket.
+	Bucket string
+	Key    string
+	// SubResources are the signed query parameters, such as acl or
+	// versionId
+	SubResources url.Values
+	// SignedHeaders are the canonical amz headers that were signed, by
+	// lower case name, with the values of a header joined by commas
+	SignedHeaders map[string]string
+	// Time is the date of requests signed in the Authorization header, and
+	// Expires when presigned requests expire
+	Time      time.Time
+	Expires   time.Time
+	Presigned bool
+}
+
+// verifiedRequest returns the VerifiedRequest of the request v2 built the
+// string to sign of, signed by cred
+func verifiedRequest(v2 *signer, cred Credential) VerifiedRequest {
+	resource, _, _ := strings.Cut(v2.canonicalResource, "?")
+	vr := VerifiedRequest{
+		AccessKeyID:   cred.AccessKeyID,
+		Tenant:        cred.Tenant,
+		Method:        v2.Request.Method,
+		SubResources:  make(url.Values),
+		SignedHeaders: make(map[string]string),
+	}
+
+	path := strings.TrimPrefix(resource, "/")
+	if v2.BucketInResource != BucketInResourceNever {
+		vr.Bucket, path, _ = strings.Cut(path, "/")
+		vr.Bucket = unescapePath(vr.Bucket)
+	}
+	vr.Key = unescapePath(path)
+
+	for name, values := range v2.Request.URL.Query() {
+		if subResources[name] || slices.Contains(v2.SubResources, name) {
+			vr.SubResources[name] = values
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(v2.canonicalAmzHeaders, "\n"), "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			vr.SignedHeaders[name] = value
+		}
+	}
+	return vr
+}
+
+// unescapePath returns the unescaped path, or the path as it is if it is
+// not validly escaped
+func unescapePath(path string) string {
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		return unescaped
+	}
+	return path
+}
+
+type verifiedRequestContextKey struct{}
+
+// VerifiedRequestFromContext returns the VerifiedRequest of the request
+// verified by the Verifier middleware
+func VerifiedRequestFromContext(ctx context.Context) (VerifiedRequest, bool) {
+	vr, ok := ctx.Value(verifiedRequestContextKey{}).(VerifiedRequest)
+	return vr, ok
+}
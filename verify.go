@@ -0,0 +1,559 @@
+package s3v2
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Credential is an access key that requests can be signed with, and the
+// tenant that owns it
+type Credential struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Tenant          string
+	// AlternateSecrets are also accepted for the access key, such as the
+	// old secret while the key is rotated. The Credential returned by
+	// Verify has the secret that matched as its SecretAccessKey.
+	AlternateSecrets []string
+}
+
+// Keyring looks up the credential of the access key id that signed a
+// request, such as from a database or cache. The context is the context of
+// the request being verified.
+type Keyring interface {
+	Lookup(ctx context.Context, accessKeyID string) (Credential, error)
+}
+
+// SecretLookup returns the secret access key for an access key id, or an
+// error if the access key id is unknown
+type SecretLookup func(accessKeyID string) (secretAccessKey string, err error)
+
+// Lookup implements Keyring for a single tenant
+func (f SecretLookup) Lookup(ctx context.Context, accessKeyID string) (Credential, error) {
+	secret, err := f(accessKeyID)
+	if err != nil {
+		return Credential{}, err
+	}
+	return Credential{AccessKeyID: accessKeyID, SecretAccessKey: secret}, nil
+}
+
+// SecretsLookup returns the secret access keys that are accepted for an
+// access key id, such as the new and old secret while the key is rotated,
+// or an error if the access key id is unknown
+type SecretsLookup func(accessKeyID string) (secretAccessKeys []string, err error)
+
+// Lookup implements Keyring for a single tenant
+func (f SecretsLookup) Lookup(ctx context.Context, accessKeyID string) (Credential, error) {
+	secrets, err := f(accessKeyID)
+	if err != nil {
+		return Credential{}, err
+	}
+	if len(secrets) == 0 {
+		return Credential{}, fmt.Errorf("no secrets for access key %q", accessKeyID)
+	}
+	return Credential{
+		AccessKeyID:      accessKeyID,
+		SecretAccessKey:  secrets[0],
+		AlternateSecrets: secrets[1:],
+	}, nil
+}
+
+// matchSecret returns the credential with the secret, of its secret and
+// alternate secrets, that computes signature for the string to sign of v2
+func matchSecret(v2 signer, cred Credential, signature string) (Credential, bool) {
+	if EqualSignatures(v2.computeSignature(cred.SecretAccessKey), signature) {
+		return cred, true
+	}
+	for _, secret := range cred.AlternateSecrets {
+		if EqualSignatures(v2.computeSignature(secret), signature) {
+			matched := cred
+			matched.SecretAccessKey = secret
+			return matched, true
+		}
+	}
+	return Credential{}, false
+}
+
+// VerifyError is returned when a request fails verification. Code is the
+// S3 error code a server should respond with.
+type VerifyError struct {
+	Code       string
+	Message    string
+	StatusCode int
+
+	// set when the signature does not match
+	AccessKeyID       string
+	StringToSign      string
+	SignatureProvided string
+
+	// Detail explains the mismatch, when the Verifier has DetailVerbose
+	// and could find out why
+	Detail string
+
+	// set when the request time is too skewed
+	RequestTime    string
+	ServerTime     string
+	MaxAllowedSkew time.Duration
+
+	// Err is the cause of the failure, such as ErrSignatureMismatch or
+	// the error of the Keyring, if any
+	Err error
+}
+
+func (e *VerifyError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+func errAccessDenied(message string) *VerifyError {
+	return &VerifyError{Code: "AccessDenied", Message: message, StatusCode: http.StatusForbidden}
+}
+
+var errMissingAuth = errAccessDenied("Access Denied")
+
+func errInvalidAccessKeyID(err error) *VerifyError {
+	return &VerifyError{
+		Code:       "InvalidAccessKeyId",
+		Message:    "The AWS Access Key Id you provided does not exist in our records.",
+		StatusCode: http.StatusForbidden,
+		Err:        err,
+	}
+}
+
+func errRequestTimeTooSkewed(requestTime string, serverTime time.Time, maxSkew time.Duration) *VerifyError {
+	return &VerifyError{
+		Code:           "RequestTimeTooSkewed",
+		Message:        "The difference between the request time and the current time is too large.",
+		StatusCode:     http.StatusForbidden,
+		RequestTime:    requestTime,
+		ServerTime:     serverTime.UTC().Format(time.RFC3339),
+		MaxAllowedSkew: maxSkew,
+	}
+}
+
+// errMalformedPresign returns the error of a presigned query that fails
+// ParsePresignedQuery, with the message S3 responds with
+func errMalformedPresign(err error) *VerifyError {
+	var verr *VerifyError
+	var perr *PresignQueryError
+	if errors.As(err, &perr) && perr.Param == "Expires" && perr.Value != "" {
+		verr = errAccessDenied("Invalid date (should be seconds since epoch): " + perr.Value)
+	} else {
+		verr = errAccessDenied("Query-string authentication requires the Signature, Expires and AWSAccessKeyId parameters")
+	}
+	verr.Err = err
+	return verr
+}
+
+func errSignatureDoesNotMatch(accessKeyID, stringToSign, signature string) *VerifyError {
+	return &VerifyError{
+		Code:              "SignatureDoesNotMatch",
+		Message:           "The request signature we calculated does not match the signature you provided. Check your key and signing method.",
+		StatusCode:        http.StatusForbidden,
+		AccessKeyID:       accessKeyID,
+		StringToSign:      stringToSign,
+		SignatureProvided: signature,
+		Err:               ErrSignatureMismatch,
+	}
+}
+
+// Verifier checks the V2 signatures of incoming requests, in either the
+// Authorization header or the query string of presigned requests
+type Verifier struct {
+	keyring   Keyring
+	pathStyle bool
+	maxSkew   time.Duration
+	grace     time.Duration
+	clock     Clock
+	// headerAllowlist restricts the canonical headers, if set
+	headerAllowlist  []string
+	subResources     []string
+	pathEncoding     PathEncoding
+	keyNormalization KeyNormalization
+	bucketInResource BucketInResource
+	endpointSuffix   string
+	signatureMethod  SignatureMethod
+	authScheme       string
+	headerPrefixes   []string
+	authorizer       Authorizer
+
+	detail      DetailLevel
+	preflight   bool
+	replayGuard ReplayGuard
+	metrics     Metrics
+	cache       *VerifierCache
+}
+
+// DefaultMaxClockSkew is the largest difference between the request time
+// and the server time S3 allows
+const DefaultMaxClockSkew = 15 * time.Minute
+
+// VerifierOption configures a Verifier
+type VerifierOption func(*Verifier)
+
+// WithPathStyleRequests verifies requests as path style, for servers that
+// never use virtual host style buckets
+func WithPathStyleRequests() VerifierOption {
+	return func(v *Verifier) {
+		v.pathStyle = true
+	}
+}
+
+// WithMaxClockSkew sets the largest difference allowed between the Date (or
+// x-amz-date) of a request and the server time, DefaultMaxClockSkew if not
+// set. A zero maxSkew disables the check.
+func WithMaxClockSkew(maxSkew time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.maxSkew = maxSkew
+	}
+}
+
+// WithExpiryGrace accepts presigned requests for up to grace after they
+// expire, to tolerate the clocks of the issuer of presigned URLs and the
+// server drifting apart. The grace a request was accepted with is in its
+// Verification.
+func WithExpiryGrace(grace time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.grace = grace
+	}
+}
+
+// WithPreflightPassThrough passes CORS preflight requests, which browsers
+// send without credentials, through the Middleware unverified, so next can
+// answer them
+func WithPreflightPassThrough() VerifierOption {
+	return func(v *Verifier) {
+		v.preflight = true
+	}
+}
+
+// WithVerifierClock checks request times and expiries against the time
+// returned by now instead of the local clock, like WithVerifierClockSource
+func WithVerifierClock(now func() time.Time) VerifierOption {
+	return WithVerifierClockSource(ClockFunc(now))
+}
+
+// WithVerifierClockSource checks request times and expiries, and expires
+// cached verifications, against the time of clock instead of the local
+// clock
+func WithVerifierClockSource(clock Clock) VerifierOption {
+	return func(v *Verifier) {
+		v.clock = clock
+	}
+}
+
+// WithVerifierHeaderAllowlist verifies requests signed with only the
+// canonical headers named in names, for clients signing with
+// WithHeaderAllowlist
+func WithVerifierHeaderAllowlist(names ...string) VerifierOption {
+	allowlist := lowerNames(names)
+	return func(v *Verifier) {
+		v.headerAllowlist = allowlist
+	}
+}
+
+// WithVerifierEndpointSuffix verifies requests signed with
+// WithEndpointSuffix(suffix), taking the bucket of virtual host style
+// requests from the part of the host before suffix rather than guessing it
+func WithVerifierEndpointSuffix(suffix string) VerifierOption {
+	return func(v *Verifier) {
+		v.endpointSuffix = strings.ToLower(suffix)
+	}
+}
+
+// WithVerifierSignatureMethod verifies requests signed with
+// WithSignatureMethod(method), HmacSHA1 if not set
+func WithVerifierSignatureMethod(method SignatureMethod) VerifierOption {
+	return func(v *Verifier) {
+		v.signatureMethod = method
+	}
+}
+
+// WithVerifierAuthScheme verifies requests whose Authorization is labeled
+// with scheme, such as those signed with WithAuthScheme(scheme), instead of
+// "AWS"
+func WithVerifierAuthScheme(scheme string) VerifierOption {
+	return func(v *Verifier) {
+		v.authScheme = scheme
+	}
+}
+
+// WithVerifierHeaderPrefixes verifies requests signed with
+// WithHeaderPrefixes(prefixes...), whose canonical headers are those
+// starting with any of prefixes instead of "x-amz"
+func WithVerifierHeaderPrefixes(prefixes ...string) VerifierOption {
+	return func(v *Verifier) {
+		v.headerPrefixes = prefixes
+	}
+}
+
+// WithVerifierSubResources verifies requests signed with
+// WithSubResources(names...)
+func WithVerifierSubResources(names ...string) VerifierOption {
+	return func(v *Verifier) {
+		v.subResources = names
+	}
+}
+
+// NewVerifier returns a Verifier that looks up the credential of the access
+// key id that signed a request in keyring
+func NewVerifier(keyring Keyring, opts ...VerifierOption) *Verifier {
+	v := &Verifier{
+		keyring: keyring,
+		maxSkew: DefaultMaxClockSkew,
+		clock:   SystemClock,
+		metrics: nopMetrics{},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verification is the outcome of verifying a request
+type Verification struct {
+	Credential Credential
+	// Expires is when a presigned request expires
+	Expires time.Time
+	// Grace is how long after Expires a presigned request was accepted,
+	// within the grace of WithExpiryGrace
+	Grace time.Duration
+	// Request is what the request was signed for
+	Request VerifiedRequest
+}
+
+// Verify checks the signature of the request, and returns the credential
+// that signed it. A *VerifyError is returned when the request is not
+// authenticated.
+func (v *Verifier) Verify(r *http.Request) (Credential, error) {
+	result, err := v.VerifyRequest(r)
+	return result.Credential, err
+}
+
+// VerifyRequest checks the signature of the request like Verify, and
+// returns the outcome of the verification
+func (v *Verifier) VerifyRequest(r *http.Request) (Verification, error) {
+	result, err := v.verify(r)
+	if err != nil {
+		reason := errMissingAuth.Code
+		var verr *VerifyError
+		if errors.As(err, &verr) {
+			reason = verr.Code
+		}
+		v.metrics.VerifyFailed(reason)
+		if v.detail == DetailMinimal {
+			err = errSignatureDoesNotMatchMinimal(err)
+		}
+	}
+	return result, err
+}
+
+func (v *Verifier) verify(r *http.Request) (Verification, error) {
+	var accessKeyID, signature, date string
+	// requestTime is the time of requests signed in the Authorization
+	// header, and validUntil when the signature stops being accepted
+	var requestTime, validUntil time.Time
+	var result Verification
+
+	query := r.URL.Query()
+	switch {
+	case r.Header.Get("Authorization") != "":
+		var err error
+		accessKeyID, signature, err = parseAuthorization(r.Header.Get("Authorization"), v.authScheme)
+		if err != nil {
+			return Verification{}, errAccessDenied(err.Error())
+		}
+		date = dateField(r.Header)
+		requestTime, err = v.checkClockSkew(r.Header)
+		if err != nil {
+			return Verification{}, err
+		}
+		if v.maxSkew != 0 {
+			validUntil = requestTime.Add(v.maxSkew)
+		}
+	case query.Get("Signature") != "":
+		params, err := ParsePresignedQuery(query)
+		if err != nil {
+			return Verification{}, errMalformedPresign(err)
+		}
+		accessKeyID = params.AccessKeyID
+		signature = params.Signature
+		date = query.Get("Expires")
+
+		// expiries are in whole seconds
+		late := time.Duration(v.clock.Now().Unix()-params.Expires.Unix()) * time.Second
+		if late > v.grace {
+			verr := errAccessDenied("Request has expired")
+			verr.Err = ErrExpiredPresign
+			return Verification{}, verr
+		}
+		result.Expires = params.Expires
+		if late > 0 {
+			result.Grace = late
+		}
+		validUntil = params.Expires.Add(v.grace)
+
+		// the session token of temporary credentials is signed as a
+		// canonical amz header
+		if params.SecurityToken != "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("X-Amz-Security-Token", params.SecurityToken)
+		}
+	default:
+		return Verification{}, errMissingAuth
+	}
+
+	v2 := signer{
+		Request:          r,
+		PathStyle:        v.pathStyle,
+		HeaderAllowlist:  v.headerAllowlist,
+		SubResources:     v.subResources,
+		PathEncoding:     v.pathEncoding,
+		BucketInResource: v.bucketInResource,
+		EndpointSuffix:   v.endpointSuffix,
+		SignatureMethod:  v.signatureMethod,
+		HeaderPrefixes:   v.headerPrefixes,
+	}
+	v2.buildStringToSign(date)
+
+	// the key is checked as it was sent first, since most clients sign
+	// keys as-is, and then in the normalization form
+	cred, err := v.verifySignature(r.Context(), accessKeyID, v2, signature, date, validUntil)
+	if errors.Is(err, ErrSignatureMismatch) && v.keyNormalization != KeyAsIs {
+		normalized := v2
+		normalized.KeyNormalization = v.keyNormalization
+		normalized.buildStringToSign(date)
+		if normalized.stringToSign != v2.stringToSign {
+			if matched, nerr := v.verifySignature(r.Context(), accessKeyID, normalized, signature, date, validUntil); nerr == nil {
+				cred, err, v2 = matched, nil, normalized
+			}
+		}
+	}
+	if err != nil {
+		return Verification{}, err
+	}
+	result.Credential = cred
+	result.Request = verifiedRequest(&v2, cred)
+	result.Request.Time = requestTime
+	result.Request.Expires = result.Expires
+	result.Request.Presigned = !result.Expires.IsZero()
+
+	if v.replayGuard != nil && !requestTime.IsZero() &&
+		v.replayGuard.Seen(signature, requestTime) {
+		return Verification{}, errAccessDenied("Request has already been used")
+	}
+
+	return result, nil
+}
+
+// verifySignature looks up the credential of the access key id and checks
+// the signature of the string to sign built by v2 with date, through the
+// cache if there is one
+func (v *Verifier) verifySignature(ctx context.Context, accessKeyID string, v2 signer, signature, date string, validUntil time.Time) (Credential, error) {
+	now := v.clock.Now()
+	if v.cache != nil {
+		if cred, ok := v.cache.verified(accessKeyID, v2.stringToSign, signature, now); ok {
+			return cred, nil
+		}
+	}
+
+	var cred Credential
+	var err error
+	if v.cache != nil {
+		cred, err = v.cache.lookup(ctx, v.keyring, accessKeyID, now)
+	} else {
+		cred, err = v.keyring.Lookup(ctx, accessKeyID)
+	}
+	if err != nil {
+		return Credential{}, errInvalidAccessKeyID(err)
+	}
+
+	matched, ok := matchSecret(v2, cred, signature)
+	if !ok {
+		verr := errSignatureDoesNotMatch(accessKeyID, v2.stringToSign, signature)
+		if v.detail == DetailVerbose {
+			verr.Detail = explainMismatch(v2, cred, signature, date)
+		}
+		return Credential{}, verr
+	}
+	cred = matched
+
+	// signatures without a bound on their validity, when there is no
+	// clock skew window, could be replayed from the cache
+	if v.cache != nil && !validUntil.IsZero() {
+		v.cache.addVerified(cred, v2.stringToSign, signature, now, validUntil)
+	}
+	return cred, nil
+}
+
+// checkClockSkew checks the request time is within the allowed skew of the
+// server time, and returns the request time
+func (v *Verifier) checkClockSkew(header http.Header) (time.Time, error) {
+	requestTime := header.Get("X-Amz-Date")
+	if requestTime == "" {
+		requestTime = header.Get("Date")
+	}
+	t, err := parseRequestTime(requestTime)
+	if err != nil {
+		return time.Time{}, errAccessDenied("AWS authentication requires a valid Date or x-amz-date header")
+	}
+
+	if v.maxSkew == 0 {
+		return t, nil
+	}
+
+	now := v.clock.Now()
+	if skew := now.Sub(t); skew > v.maxSkew || skew < -v.maxSkew {
+		return time.Time{}, errRequestTimeTooSkewed(requestTime, now, v.maxSkew)
+	}
+	return t, nil
+}
+
+// parseRequestTime parses a Date or x-amz-date value in any of the formats
+// clients send
+func parseRequestTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC1123Z, value); err == nil {
+		return t, nil
+	}
+	return http.ParseTime(value)
+}
+
+// EqualSignatures compares two signatures in constant time, so comparing a
+// computed signature with a provided one does not leak timing information
+// about how much of the signature matched
+func EqualSignatures(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// ParseAuthorization splits an Authorization header of the form
+// "AWS AccessKeyId:Signature" into the access key id and signature
+func ParseAuthorization(auth string) (accessKeyID, signature string, err error) {
+	return parseAuthorization(auth, defaultAuthScheme)
+}
+
+// parseAuthorization parses an Authorization header like
+// ParseAuthorization, labeled with scheme, or "AWS" if it is empty
+func parseAuthorization(auth, scheme string) (accessKeyID, signature string, err error) {
+	if scheme == "" {
+		scheme = defaultAuthScheme
+	}
+	scheme += " "
+	if !strings.HasPrefix(auth, scheme) {
+		return "", "", fmt.Errorf("unsupported authorization type")
+	}
+
+	credential := strings.TrimPrefix(auth, scheme)
+	i := strings.LastIndex(credential, ":")
+	if i <= 0 || i == len(credential)-1 {
+		return "", "", fmt.Errorf("authorization header is invalid")
+	}
+	return credential[:i], credential[i+1:], nil
+}